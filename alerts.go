@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/bign8/monet/internal/alert"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// notify lets whoever isn't watching the chart know that alerts fired or cleared on a target:
+// the window title always, the bell if asked for (see --bell) and the actions (see --exec and --webhook) unless replaying
+func (m model) notify(target int, changes []alert.Change) tea.Cmd {
+	if len(changes) == 0 {
+		return nil
+	}
+	cmds := []tea.Cmd{tea.SetWindowTitle(m.title())}
+	s := m.targets[target]
+	rang := false
+	for _, c := range changes {
+		if c.Firing && m.bell && !rang {
+			cmds, rang = append(cmds, ring(s.label(), c.Rule)), true
+		}
+		if m.replay != nil {
+			continue // the recording already happened, nobody needs paging about it
+		}
+		n := alert.NewNotice(s.label(), s.ping.Target(), c)
+		cmds = append(cmds, func() tea.Msg {
+			if err := m.actions.Run(n); err != nil {
+				return printf(`alert action: %s: %s`, n.Target, err)()
+			}
+			return nil
+		})
+	}
+	return tea.Batch(cmds...)
+}
+
+// ring rings the terminal bell with a line saying why (bubbletea doesn't have a command for the bell,
+// but the lines it prints above the chart are written as is, between frames rather than in the middle of one)
+func ring(target string, rule alert.Rule) tea.Cmd {
+	return printf("%s: %s alert: %s\a", target, rule.Severity, rule)
+}
+
+// title is the window title, naming the most severe alert firing (if any)
+func (m model) title() string {
+	var worst alert.Rule
+	var which string
+	for i, s := range m.targets {
+		if m.mtr != nil && i != m.focus {
+			continue // see View
+		}
+		if r, ok := s.alerts.Firing(); ok && r.Severity > worst.Severity {
+			worst, which = r, s.label()
+		}
+	}
+	if which == `` {
+		return `Checking...`
+	}
+	return fmt.Sprintf(`%s %s: %s`, worst.Severity, which, worst.Raise)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bign8/monet/internal/alert"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// run runs cmd (and every command it batches), returning the messages that weren't batches
+func run(cmd tea.Cmd) (msgs []tea.Msg) {
+	if cmd == nil {
+		return nil
+	}
+	switch msg := cmd().(type) {
+	case nil:
+	case tea.BatchMsg:
+		for _, cmd := range msg {
+			msgs = append(msgs, run(cmd)...)
+		}
+	default:
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+// printed is a line that would have been printed above the chart
+type printed string
+
+func TestNotify(t *testing.T) {
+	defer func(was func(string) tea.Cmd) { printLine = was }(printLine)
+	printLine = func(line string) tea.Cmd {
+		return func() tea.Msg { return printed(line) }
+	}
+
+	key := func(r rune) tea.Msg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}} }
+	tests := []struct {
+		name    string
+		keys    []tea.Msg
+		status  int  // of the webhook
+		bell    bool // --bell
+		replay  bool // events come from a recording
+		notices []string
+		printed []string // lines printed above the chart
+	}{
+		{name: `fires`, keys: []tea.Msg{key('w')}, status: http.StatusOK, notices: []string{`a warning firing`}},
+		{name: `clears`, keys: []tea.Msg{key('w'), key('W')}, status: http.StatusNoContent, notices: []string{`a warning firing`, `a warning cleared`}},
+		{name: `escalates`, keys: []tea.Msg{key('w'), key('e')}, status: http.StatusOK, notices: []string{`a warning firing`, `a warning cleared`, `a critical firing`}},
+		{name: `webhook down`, keys: []tea.Msg{key('w')}, status: http.StatusBadGateway, notices: []string{`a warning firing`}, printed: []string{`alert action: a: webhook: 502 Bad Gateway`}},
+		{name: `bell`, keys: []tea.Msg{key('w')}, status: http.StatusOK, bell: true, notices: []string{`a warning firing`}, printed: []string{"a: warning alert: forced from the keyboard\a"}},
+		{name: `replaying`, keys: []tea.Msg{key('w')}, status: http.StatusOK, replay: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var notices []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var n alert.Notice
+				if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+					t.Errorf(`webhook: %v`, err)
+				}
+				if r.Method != http.MethodPost || r.Header.Get(`Content-Type`) != `application/json` {
+					t.Errorf(`webhook: %s with %s`, r.Method, r.Header.Get(`Content-Type`))
+				}
+				notices = append(notices, fmt.Sprintf(`%s %s %s`, n.Target, n.Severity, n.State))
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			m := testModel()
+			m.actions = alert.Actions{Webhook: srv.URL}
+			m.bell = tt.bell
+			if tt.replay {
+				m.replay = &replay{}
+			}
+			var lines []string
+			for _, msg := range tt.keys {
+				next, cmd := m.Update(msg)
+				m = next.(model)
+				for _, msg := range run(cmd) {
+					if line, ok := msg.(printed); ok {
+						lines = append(lines, string(line))
+					}
+				}
+			}
+			if strings.Join(notices, `, `) != strings.Join(tt.notices, `, `) {
+				t.Errorf(`notices %q, want %q`, notices, tt.notices)
+			}
+			if strings.Join(lines, `, `) != strings.Join(tt.printed, `, `) {
+				t.Errorf(`printed %q, want %q`, lines, tt.printed)
+			}
+		})
+	}
+}
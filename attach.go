@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bign8/monet/internal/alert"
+	"github.com/bign8/monet/internal/probe"
+	"github.com/bign8/monet/internal/record"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// frame is a line (of JSON) sent by the daemon to attached terminals.
+//
+// Every connection starts with a hello, the history of every target and a live frame; after that frames arrive as things happen.
+type frame struct {
+	Hello   []attachTarget `json:"hello,omitempty"`   // every target being probed (first frame only)
+	Target  int            `json:"target"`            // index into hello
+	History []pingPoint    `json:"history,omitempty"` // what happened before attaching (in chunks, oldest first)
+	Live    bool           `json:"live,omitempty"`    // history is done, live frames follow
+	Event   *record.Event  `json:"event,omitempty"`   // something happened to a probe
+	Lost    *probeID       `json:"lost,omitempty"`    // the daemon gave up on a probe
+}
+
+type attachTarget struct {
+	Name     string        `json:"name"`
+	Target   string        `json:"target"`
+	Interval time.Duration `json:"interval"`
+}
+
+// probeID tells probes apart, IDs and sequence numbers wrap around so the time it was sent is part of it
+type probeID struct {
+	ID   int       `json:"id"`
+	Seq  int       `json:"seq"`
+	Sent time.Time `json:"sent"`
+}
+
+// how many probes go in a single history frame
+const historyChunk = 500
+
+// how many frames an attached terminal can fall behind before it's dropped
+const attachBacklog = 1024
+
+// socketPath is where the daemon listens for `monet attach` (unless told otherwise with --socket)
+func socketPath() string {
+	dir := os.Getenv(`XDG_RUNTIME_DIR`)
+	if dir == `` {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, `monet.sock`)
+}
+
+// listenUnix opens the daemon's socket, cleaning up after a daemon that didn't get to
+func listenUnix(path string) (net.Listener, error) {
+	if conn, err := net.Dial(`unix`, path); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf(`another daemon is listening on %s`, path)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return net.Listen(`unix`, path)
+}
+
+// client is a terminal attached to the daemon
+type client struct {
+	conn   net.Conn
+	frames chan frame
+	gone   chan struct{} // closed once the terminal stops listening
+}
+
+// snapshot is everything a newly attached terminal needs to catch up
+func (e engine) snapshot() []frame {
+	hello := make([]attachTarget, len(e.targets))
+	for i, s := range e.targets {
+		hello[i] = attachTarget{Name: s.name, Target: s.ping.Target(), Interval: s.ping.Interval()}
+	}
+	frames := []frame{{Hello: hello}}
+	for i, s := range e.targets {
+		for start := 0; start < len(s.data); start += historyChunk {
+			chunk := s.data[start:min(start+historyChunk, len(s.data))]
+			frames = append(frames, frame{Target: i, History: append([]pingPoint(nil), chunk...)})
+		}
+	}
+	return append(frames, frame{Live: true})
+}
+
+// attached starts writing frames to a terminal, the snapshot first
+func attached(conn net.Conn, snapshot []frame) *client {
+	c := &client{conn: conn, frames: make(chan frame, attachBacklog), gone: make(chan struct{})}
+	go func() {
+		defer close(c.gone)
+		defer conn.Close()
+		w := bufio.NewWriter(conn)
+		enc := json.NewEncoder(w)
+		for _, f := range snapshot {
+			if enc.Encode(f) != nil {
+				return
+			}
+		}
+		if w.Flush() != nil {
+			return
+		}
+		for f := range c.frames {
+			if enc.Encode(f) != nil {
+				return
+			}
+			if len(c.frames) == 0 && w.Flush() != nil {
+				return // flush once caught up
+			}
+		}
+	}()
+	return c
+}
+
+// broadcast sends a frame to every attached terminal, dropping any that have fallen too far behind
+func broadcast(clients map[*client]bool, f frame) {
+	for c := range clients {
+		select {
+		case <-c.gone:
+			slog.Info(`terminal detached`)
+			delete(clients, c)
+		case c.frames <- f:
+		default:
+			slog.Warn(`dropping attached terminal (too far behind)`, `remote`, c.conn.RemoteAddr().String())
+			close(c.frames)
+			delete(clients, c)
+		}
+	}
+}
+
+// attach connects to a running daemon: `monet attach [socket]`
+func attach(path string) (engine, *json.Decoder, net.Conn) {
+	conn, err := net.Dial(`unix`, path)
+	chk(`Error attaching (is monet daemon running?)`, err)
+	e, dec := catchUp(conn)
+	return e, dec, conn
+}
+
+// catchUp reads what the daemon has seen so far off the connection, returning once the live frames start
+func catchUp(conn io.Reader) (engine, *json.Decoder) {
+	dec := json.NewDecoder(bufio.NewReader(conn))
+
+	var hello frame
+	chk(`Error reading from daemon`, dec.Decode(&hello))
+	e := engine{targets: make([]series, len(hello.Hello))}
+	for i, t := range hello.Hello {
+		ping := probe.NewFake(t.Target, nil) // never started, the daemon does the probing
+		ping.SetInterval(t.Interval)
+		e.targets[i] = series{name: t.Name, ping: ping, recent: recent, alerts: alert.NewTracker(rules, slow)}
+	}
+	for {
+		var f frame
+		chk(`Error reading from daemon`, dec.Decode(&f))
+		if f.Live {
+			return e, dec
+		}
+		if f.Target < 0 || f.Target >= len(e.targets) {
+			chk(`Error reading from daemon`, fmt.Errorf(`unknown target %d`, f.Target))
+		}
+		e.targets[f.Target].restore(f.History)
+	}
+}
+
+// message carrying a frame from the daemon
+type attachMsg struct {
+	frame frame
+	dec   *json.Decoder
+}
+
+// message sent when the daemon goes away
+type detachedMsg struct {
+	err error
+}
+
+// receive waits for the next frame from the daemon
+func receive(dec *json.Decoder) tea.Cmd {
+	return func() tea.Msg {
+		var f frame
+		if err := dec.Decode(&f); err != nil {
+			return detachedMsg{err: err}
+		}
+		return attachMsg{frame: f, dec: dec}
+	}
+}
+
+// follow applies a frame from the daemon the same way the daemon did (alert rules are the terminal's own)
+func (m model) follow(f frame) (model, tea.Cmd) {
+	if f.Target < 0 || f.Target >= len(m.targets) {
+		return m, printf(`attach: unknown target %d`, f.Target)
+	}
+	switch {
+	case f.Event != nil:
+		return m.event(f.Target, f.Event.Probe())
+	case f.Lost != nil:
+		_, changes, note := m.missing(f.Target, *f.Lost, m.now())
+		if note != `` {
+			return m, tea.Batch(printf(`%s`, note), m.notify(f.Target, changes))
+		}
+		return m, m.notify(f.Target, changes)
+	}
+	return m, nil
+}
+
+// detached explains why the daemon went away
+func detached(err error) string {
+	if errors.Is(err, io.EOF) {
+		return `daemon went away`
+	}
+	return fmt.Sprintf(`daemon went away: %s`, err)
+}
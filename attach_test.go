@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/bign8/monet/internal/probe"
+	"github.com/bign8/monet/internal/record"
+)
+
+func TestAttach(t *testing.T) {
+	// the daemon has seen a few probes come back, one get lost and one still in flight
+	daemon := testModel().engine
+	sent := func(seq int) probe.Event { return sentMsg(seq).(wrappedMsg).this }
+	recv := func(seq int, rtt time.Duration) probe.Event { return recvMsg(seq, rtt).(wrappedMsg).this }
+	lost := probeID{ID: 1, Seq: 2, Sent: epoch.Add(2 * time.Second)}
+	for _, ev := range []probe.Event{sent(0), recv(0, 20*time.Millisecond), sent(1), recv(1, 35*time.Millisecond), sent(2), sent(3)} {
+		daemon.observe(0, ev)
+	}
+	daemon.missing(0, lost, epoch.Add(3*time.Second))
+
+	server, conn := net.Pipe()
+	c := attached(server, daemon.snapshot())
+	defer close(c.frames)
+
+	e, dec := catchUp(conn)
+	m := testModel()
+	m.engine, m.frames = e, dec
+	if len(m.targets) != 1 || m.targets[0].ping.Target() != `a` {
+		t.Fatalf(`targets = %v, want just a`, m.targets)
+	}
+	if got, want := state(m), state(model{engine: daemon}); got != want {
+		t.Errorf("restored %s\nwant     %s", got, want)
+	}
+	if s := m.targets[0]; s.sent != 4 || s.lost != 1 || s.pending() != 1 {
+		t.Errorf(`restored sent/lost/pending = %d/%d/%d, want 4/1/1`, s.sent, s.lost, s.pending())
+	}
+
+	event := func(ev probe.Event) *record.Event {
+		e := record.New(`a`, ev)
+		return &e
+	}
+
+	// then follows along as the one in flight comes back and more go out (one of them going missing)
+	live := []frame{
+		{Event: event(recv(3, 50*time.Millisecond))},
+		{Event: event(sent(4))},
+		{Event: event(recv(4, 25*time.Millisecond))},
+		{Event: event(sent(5))},
+		{Lost: &probeID{ID: 1, Seq: 5, Sent: epoch.Add(5 * time.Second)}},
+	}
+	for _, f := range live {
+		if f.Event != nil {
+			daemon.observe(0, f.Event.Probe())
+		} else {
+			daemon.missing(0, *f.Lost, f.Lost.Sent.Add(time.Second))
+		}
+		c.frames <- f
+		msg := receive(m.frames)()
+		if _, ok := msg.(attachMsg); !ok {
+			t.Fatalf(`got %#v, want a frame`, msg)
+		}
+		m = update(t, m, msg)
+	}
+	if got, want := state(m), state(model{engine: daemon}); got != want {
+		t.Errorf("followed %s\nwant     %s", got, want)
+	}
+	if s := m.targets[0]; s.sent != 6 || s.lost != 2 || s.pending() != 0 || s.stat.Count != 4 {
+		t.Errorf(`followed sent/lost/pending/replies = %d/%d/%d/%d, want 6/2/0/4`, s.sent, s.lost, s.pending(), s.stat.Count)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bign8/monet/internal/quality"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// colors of each call quality grade
+var gradeColors = map[string]lipgloss.Color{
+	`good`: lipgloss.Color(`2`),
+	`fair`: YELLOW,
+	`poor`: RED,
+}
+
+// callQuality renders how a call with the focused target would go right now (see package quality)
+func (m model) callQuality() string {
+	score, ok := m.targets[m.focus].alerts.Call()
+	if !ok {
+		return lipgloss.Place(m.w, 1, lipgloss.Center, lipgloss.Center, `call quality: measuring...`)
+	}
+	grade := score.Grade()
+	badge := lipgloss.NewStyle().Bold(true).Padding(0, 1).
+		Foreground(lipgloss.Color(`0`)).
+		Background(gradeColors[grade]).
+		Render(`call quality: ` + strings.ToUpper(grade))
+	line := fmt.Sprintf(`%s  MOS %.1f · R %.0f · jitter %.1fms · loss %.1f%% (last %s)`,
+		badge, score.MOS, score.R, dur2ms(score.Jitter), score.Loss*100, quality.Window)
+	return lipgloss.Place(m.w, 1, lipgloss.Center, lipgloss.Center, line)
+}
@@ -1,12 +1,17 @@
 package main
 
 import (
+	"flag"
+
 	"github.com/bign8/monet/internal/chooser"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
 func main() {
-	model := chooser.New()
+	query := flag.String(`query`, ``, `resolve this name with each provider (instead of pinging them)`)
+	flag.Parse()
+
+	model := chooser.New(*query)
 	_, err := tea.NewProgram(model).Run()
 	if err != nil {
 		panic(err)
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/bign8/monet/internal/alert"
+	"github.com/bign8/monet/internal/probe"
+	"github.com/bign8/monet/internal/record"
+)
+
+// how often the daemon probes (the same pace the TUI settles on once the screen is full)
+var daemonInterval = intervals[len(intervals)-2]
+
+// how many probes of every target the daemon remembers
+const history = 10_000
+
+// daemon probes without a terminal (for systemd and friends), logging whenever an alert fires or clears: `monet daemon [targets...]`
+func daemon(e engine, socket string) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	type event struct {
+		target int
+		ev     probe.Event
+	}
+	events := make(chan event)
+	checks := make(chan howAreYaNow)
+
+	// terminals attach over a unix socket (see attach)
+	ln, err := listenUnix(socket)
+	chk(`Error listening for terminals`, err)
+	defer ln.Close()
+	joins := make(chan net.Conn)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return // closed on the way out
+			}
+			select {
+			case joins <- conn:
+			case <-ctx.Done():
+				conn.Close()
+				return
+			}
+		}
+	}()
+	slog.Info(`waiting for terminals`, `socket`, socket)
+	clients := map[*client]bool{}
+
+	for i, s := range e.targets {
+		s.ping.SetInterval(daemonInterval)
+		slog.Info(`probing`, `target`, s.label(), `interval`, daemonInterval.String())
+		go func() {
+			for ev := range s.ping.Events() {
+				select {
+				case events <- event{target: i, ev: ev}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			for _, s := range e.targets {
+				s.ping.Stop()
+			}
+			if e.rec != nil {
+				chk(`Error closing recording`, e.rec.Close())
+			}
+			for c := range clients {
+				close(c.frames)
+			}
+			slog.Info(`stopped`)
+			return
+
+		case conn := <-joins:
+			clients[attached(conn, e.snapshot())] = true
+			slog.Info(`terminal attached`, `terminals`, len(clients))
+
+		case msg := <-events:
+			changes, note := e.observe(msg.target, msg.ev)
+			if note != `` {
+				slog.Warn(note, `target`, e.targets[msg.target].label())
+			}
+			e.report(msg.target, changes)
+			ev := record.New(e.targets[msg.target].ping.Target(), msg.ev)
+			broadcast(clients, frame{Target: msg.target, Event: &ev})
+			if msg.ev.Kind != probe.Sent {
+				continue
+			}
+			s := &e.targets[msg.target]
+			if len(s.data) > history {
+				s.data = s.data[len(s.data)-history:]
+			}
+			check := howAreYaNow{Target: msg.target, Probe: probeID{ID: msg.ev.ID, Seq: msg.ev.Seq, Sent: msg.ev.Time}}
+			time.AfterFunc(time.Second, func() {
+				select {
+				case checks <- check:
+				case <-ctx.Done():
+				}
+			})
+
+		case msg := <-checks:
+			lost, changes, note := e.missing(msg.Target, msg.Probe, time.Now())
+			if note != `` {
+				slog.Warn(note, `target`, e.targets[msg.Target].label())
+			}
+			e.report(msg.Target, changes)
+			if lost {
+				broadcast(clients, frame{Target: msg.Target, Lost: &msg.Probe})
+			}
+		}
+	}
+}
+
+// report logs alerts that fired or cleared, and takes whatever actions were asked for (see --exec and --webhook)
+func (e engine) report(target int, changes []alert.Change) {
+	s := e.targets[target]
+	for _, c := range changes {
+		if c.Firing {
+			slog.Warn(`alert`, `target`, s.label(), `severity`, c.Rule.Severity.String(), `rule`, c.Rule.String())
+		} else {
+			slog.Info(`alert cleared`, `target`, s.label(), `severity`, c.Rule.Severity.String(), `rule`, c.Rule.String(), `lost`, s.lost, `sent`, s.sent)
+		}
+		n := alert.NewNotice(s.label(), s.ping.Target(), c)
+		go func() {
+			if err := e.actions.Run(n); err != nil {
+				slog.Warn(`alert action failed`, `target`, n.Target, `error`, err.Error())
+			}
+		}()
+	}
+}
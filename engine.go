@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bign8/monet/internal/alert"
+	"github.com/bign8/monet/internal/metrics"
+	"github.com/bign8/monet/internal/probe"
+	"github.com/bign8/monet/internal/quality"
+	"github.com/bign8/monet/internal/record"
+)
+
+// engine keeps track of what happens to the probes of every target, with (model) or without (daemon) a terminal.
+//
+// It doesn't own any timers: whoever is driving it calls missing a second after every probe is sent.
+// Alert rules are checked on every event, which happen often enough to not need a timer of their own.
+type engine struct {
+	targets []series         // everything being monitored
+	rec     *record.Writer   // every event goes here too (nil when not recording)
+	metrics *metrics.Metrics // scraped by prometheus (nil when not listening, which is fine to call)
+	actions alert.Actions    // run whenever an alert fires or clears (see --exec and --webhook)
+}
+
+// newSeries starts probing target, labeling it name on the chart
+func newSeries(name, target string) series {
+	ping, err := probe.New(target)
+	chk(`Error creating prober`, err)
+	ping.SetInterval(intervals[1])
+	chk(`Error starting prober`, ping.Start())
+	return series{name: name, ping: ping, recent: recent, alerts: alert.NewTracker(rules, slow)}
+}
+
+// observe records what happened to a probe of a target, returning any alerts that fired or cleared and anything worth mentioning
+func (e engine) observe(target int, ev probe.Event) (changes []alert.Change, note string) {
+	s := &e.targets[target]
+	if e.rec != nil {
+		if err := e.rec.Write(record.New(s.ping.Target(), ev)); err != nil {
+			note = fmt.Sprintf(`record: %s`, err)
+		}
+	}
+
+	switch ev.Kind {
+	case probe.Failed:
+		e.metrics.Failed(s.label())
+		return e.check(target, ev.Time), fmt.Sprintf(`%s: %s: id: %d; seq: %d; %s`, s.label(), ev.Kind, ev.ID, ev.Seq, ev.Err)
+
+	case probe.Sent:
+		e.metrics.Sent(s.label())
+		s.sent++
+		s.flying.sent(flightKey{ev.ID, ev.Seq}, ev.Time)
+		s.data = append(s.data, pingPoint{
+			ID:       ev.ID,
+			Seq:      ev.Seq,
+			Sent:     ev.Time,
+			Interval: s.ping.Interval(),
+		})
+		s.alerts.Sent(ev.Time)
+		return e.check(target, ev.Time), note
+	}
+
+	sent, v := s.flying.reply(flightKey{ev.ID, ev.Seq}, ev.Rtt, ev.Time)
+	switch v {
+	case stray:
+		return e.check(target, ev.Time), fmt.Sprintf("recv: id: %d; seq: %d; not in flight", ev.ID, ev.Seq)
+	case duplicate, late:
+		// the probe already counted (as received or lost), the reply only shows up in s.flying's counts
+		if i := s.at(sent); i >= 0 {
+			s.data[i].Odd = true
+		}
+		return e.check(target, ev.Time), note
+	}
+
+	e.metrics.Received(s.label(), ev.Rtt)
+	s.stat.Add(ev.Rtt)
+	s.recent.Add(ev.Time, ev.Rtt)
+	s.quant.Add(ev.Rtt)
+	if s.last != 0 {
+		s.spread.Add(quality.Change(s.last, ev.Rtt))
+	}
+	s.last, s.best, s.worst = ev.Rtt, fastest(s.best, ev.Rtt), max(s.worst, ev.Rtt)
+	if ev.From != `` {
+		s.from = ev.From
+	}
+	if len(ev.Phases) > 0 {
+		s.phases = ev.Phases
+	}
+	s.alerts.Add(alert.Sample{At: sent, Rtt: ev.Rtt})
+	if i := s.at(sent); i >= 0 {
+		s.data[i].Rtt = ev.Rtt
+		s.data[i].Recv = ev.Time
+		s.data[i].Odd = v == reordered
+	}
+	return e.check(target, ev.Time), note
+}
+
+// missing checks on a probe a second after it was sent (now being when that happened), a probe without a reply by then is lost
+func (e engine) missing(target int, p probeID, now time.Time) (lost bool, changes []alert.Change, note string) {
+	s := &e.targets[target]
+	answered, ok := s.flying.expire(flightKey{p.ID, p.Seq}, p.Sent)
+	if !ok {
+		return false, nil, fmt.Sprintf("how-are-ya-now: id: %d; seq: %d; not found", p.ID, p.Seq)
+	}
+	if answered {
+		return false, nil, `` // all good, we've received the packed
+	}
+
+	if i := s.at(p.Sent); i >= 0 {
+		s.data[i].Lost = true
+	}
+	s.lost++
+	s.alerts.Add(alert.Sample{At: p.Sent, Lost: true})
+	e.metrics.Lost(s.label())
+	return true, e.check(target, now), ``
+}
+
+// check runs the alert rules of a target
+func (e engine) check(target int, now time.Time) []alert.Change {
+	return e.alerted(target, e.targets[target].alerts.Check(now))
+}
+
+// force raises (or clears, with alert.None) an alert on a target by hand
+func (e engine) force(target int, severity alert.Severity, now time.Time) []alert.Change {
+	return e.alerted(target, e.targets[target].alerts.Force(severity, now))
+}
+
+// alerted passes on alerts that fired or cleared
+func (e engine) alerted(target int, changes []alert.Change) []alert.Change {
+	if len(changes) > 0 {
+		s := e.targets[target]
+		e.metrics.Alert(s.label(), int(s.alerts.Severity()))
+	}
+	return changes
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bign8/monet/internal/alert"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// incident is an alert.Incident and the target it happened to
+type incident struct {
+	alert.Incident
+	target int
+}
+
+// allIncidents of every visible target, oldest first
+func (m model) allIncidents() []incident {
+	var all []incident
+	for i, s := range m.targets[:m.visible()] {
+		for _, in := range s.alerts.Incidents() {
+			all = append(all, incident{Incident: in, target: i})
+		}
+	}
+	slices.SortStableFunc(all, func(a, b incident) int { return a.Start.Compare(b.Start) })
+	return all
+}
+
+// how tall the timeline is (the same as the chart and its histogram, so toggling doesn't make the screen jump)
+const timelineHeight = 25
+
+// timeline renders the session's incidents: a line summing them up, a strip per target and a table of the latest ones
+func (m model) timeline(width int, now time.Time) string {
+	all := m.allIncidents()
+	var since time.Time
+	for _, s := range m.targets[:m.visible()] {
+		if t := s.alerts.Since(); !t.IsZero() && (since.IsZero() || t.Before(since)) {
+			since = t
+		}
+	}
+
+	lines := []string{``}
+	if len(all) == 0 {
+		lines = append(lines, lipgloss.PlaceHorizontal(width, lipgloss.Center, fmt.Sprintf(`No incidents since %s`, since.Format(time.TimeOnly))))
+	} else {
+		longest := slices.MaxFunc(all, func(a, b incident) int { return int(a.Duration(now) - b.Duration(now)) })
+		noun := `incidents`
+		if len(all) == 1 {
+			noun = `incident`
+		}
+		line := fmt.Sprintf(`%d %s since %s, longest %s at %s`, len(all), noun, since.Format(time.TimeOnly), lasted(longest.Duration(now)), longest.Start.Format(`15:04`))
+		lines = append(lines, lipgloss.PlaceHorizontal(width, lipgloss.Center, line))
+	}
+	lines = append(lines, ``)
+
+	// a strip per target, from the first probe until now
+	pad := 0
+	for _, s := range m.targets[:m.visible()] {
+		pad = max(pad, len(s.label()))
+	}
+	cols := max(width-pad-4, 1)
+	span := max(now.Sub(since), time.Second)
+	for _, s := range m.targets[:m.visible()] {
+		strip := make([]string, cols)
+		for c := range strip {
+			strip[c] = `─`
+		}
+		for _, in := range s.alerts.Incidents() {
+			end := now
+			if !in.Ongoing() {
+				end = in.End
+			}
+			first := int(in.Start.Sub(since) * time.Duration(cols) / span)
+			last := int(end.Sub(since) * time.Duration(cols) / span)
+			color := YELLOW // slow
+			if in.Lost > 0 {
+				color = RED
+			}
+			for c := max(first, 0); c <= min(last, cols-1); c++ {
+				strip[c] = lipgloss.NewStyle().Foreground(color).Render(`█`)
+			}
+		}
+		lines = append(lines, fmt.Sprintf(` %-*s │%s`, pad, s.label(), strings.Join(strip, ``)))
+	}
+	axis := fmt.Sprintf(`%*s%s`, pad+3, ``, since.Format(time.TimeOnly))
+	lines = append(lines, axis+fmt.Sprintf(`%*s`, max(pad+3+cols-len(axis), 0), now.Format(time.TimeOnly)), ``)
+
+	// the latest incidents that fit (newest first)
+	lines = append(lines, fmt.Sprintf(` %-8s  %-8s  %8s  %-*s  %12s  %8s`, `start`, `end`, `lasted`, pad, `target`, `lost/probes`, `worst`))
+	room := timelineHeight - len(lines)
+	for i := len(all) - 1; i >= 0 && room > 0; i-- {
+		if room == 1 && i > 0 {
+			lines = append(lines, fmt.Sprintf(` ... and %d more`, i+1))
+			break
+		}
+		in := all[i]
+		end := `ongoing`
+		if !in.Ongoing() {
+			end = in.End.Format(time.TimeOnly)
+		}
+		worst := `-`
+		if in.Worst > 0 {
+			worst = fmt.Sprintf(`%.1fms`, dur2ms(in.Worst))
+		}
+		line := fmt.Sprintf(` %-8s  %-8s  %8s  %-*s  %12s  %8s`, in.Start.Format(time.TimeOnly), end, lasted(in.Duration(now)),
+			pad, m.targets[in.target].label(), fmt.Sprintf(`%d/%d`, in.Lost, in.Probes), worst)
+		if in.Ongoing() {
+			line = lipgloss.NewStyle().Foreground(RED).Render(line)
+		}
+		lines = append(lines, line)
+		room--
+	}
+	for len(lines) < timelineHeight {
+		lines = append(lines, ``)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// lasted rounds a duration for people (to the second, unless it was shorter than that)
+func lasted(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(time.Second).String()
+}
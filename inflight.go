@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// verdict is what a reply turned out to be
+type verdict uint8
+
+const (
+	onTime    verdict = iota // the first reply to a probe, in time and in the order the probes were sent
+	reordered                // overtaken by the reply to a probe sent after it
+	late                     // after the probe was given up on
+	duplicate                // another reply to a probe that was already answered
+	stray                    // to a probe that was never sent (or was sent so long ago it's been forgotten)
+	verdicts                 // how many there are
+)
+
+func (v verdict) String() string {
+	return [...]string{`on time`, `reordered`, `late`, `duplicate`, `stray`}[v]
+}
+
+// how long probes are remembered after being sent (replies after that are strays)
+const remember = time.Minute
+
+// flightKey is what a reply says about the probe it answers (both wrap around, see flight.sent)
+type flightKey struct {
+	id, seq int
+}
+
+// flight is a probe sent and what became of it
+type flight struct {
+	key      flightKey
+	sent     time.Time // tells apart probes that share a key
+	answered bool
+	lost     bool // given up on (see howAreYaNow)
+}
+
+// flights keeps track of the probes of a target from when they're sent until a while after, so every reply can be
+// matched to the probe it answers and judged (the zero value is ready to use)
+type flights struct {
+	byKey  map[flightKey][]*flight // oldest first (there's more than one once sequence numbers wrap around)
+	order  []*flight               // every probe remembered, oldest first
+	newest time.Time               // when the latest answered probe was sent
+	counts [verdicts]int           // replies by verdict
+}
+
+// sent remembers a probe going out (forgetting any sent long enough before it)
+func (f *flights) sent(key flightKey, at time.Time) *flight {
+	if f.byKey == nil {
+		f.byKey = map[flightKey][]*flight{}
+	}
+	forget := 0
+	for forget < len(f.order) && at.Sub(f.order[forget].sent) > remember {
+		old := f.order[forget]
+		if f.byKey[old.key] = f.byKey[old.key][1:]; len(f.byKey[old.key]) == 0 {
+			delete(f.byKey, old.key)
+		}
+		forget++
+	}
+	f.order = f.order[forget:]
+
+	p := &flight{key: key, sent: at}
+	f.order = append(f.order, p)
+	f.byKey[key] = append(f.byKey[key], p)
+	return p
+}
+
+// find is the probe with key sent closest to sent (nil if there isn't one)
+func (f *flights) find(key flightKey, sent time.Time) *flight {
+	var best *flight
+	for _, p := range f.byKey[key] {
+		if best == nil || abs(p.sent.Sub(sent)) < abs(best.sent.Sub(sent)) {
+			best = p
+		}
+	}
+	return best
+}
+
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// reply matches a reply arriving at to the probe it answers, returning when that was sent and what to make of the reply
+func (f *flights) reply(key flightKey, rtt time.Duration, at time.Time) (sent time.Time, v verdict) {
+	p := f.find(key, at.Add(-rtt))
+	switch {
+	case p == nil:
+		v = stray
+	case p.answered:
+		v = duplicate
+	case p.lost:
+		v = late
+	case p.sent.Before(f.newest):
+		v = reordered
+	}
+	f.counts[v]++
+	if p == nil {
+		return time.Time{}, v
+	}
+	if !p.answered && p.sent.After(f.newest) {
+		f.newest = p.sent
+	}
+	p.answered = true
+	return p.sent, v
+}
+
+// expire gives up on a probe unless it's been answered (ok is false when the probe isn't known)
+func (f *flights) expire(key flightKey, sent time.Time) (answered, ok bool) {
+	p := f.find(key, sent)
+	if p == nil || !p.sent.Equal(sent) {
+		return false, false
+	}
+	if !p.answered {
+		p.lost = true
+	}
+	return p.answered, true
+}
+
+// awaited is true while a probe with key is still waiting on its reply
+func (f *flights) awaited(key flightKey) bool {
+	for _, p := range f.byKey[key] {
+		if !p.answered && !p.lost {
+			return true
+		}
+	}
+	return false
+}
+
+// odd describes the replies that weren't on time, like "2 late, 1 duplicate" (empty when they all were)
+func (f *flights) odd() string {
+	var parts []string
+	for v := reordered; v < verdicts; v++ {
+		if f.counts[v] > 0 {
+			parts = append(parts, fmt.Sprintf(`%d %s`, f.counts[v], v))
+		}
+	}
+	return strings.Join(parts, `, `)
+}
@@ -0,0 +1,103 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// Notice is an alert firing or clearing, as told to the outside world.
+type Notice struct {
+	Target   string    `json:"target"`  // label of the target
+	Address  string    `json:"address"` // what's being probed
+	Severity string    `json:"severity"`
+	Rule     string    `json:"rule"`
+	State    string    `json:"state"` // firing or cleared
+	Time     time.Time `json:"time"`
+}
+
+// NewNotice describes a change to an alert on a target.
+func NewNotice(target, address string, c Change) Notice {
+	n := Notice{Target: target, Address: address, Severity: c.Rule.Severity.String(), Rule: c.Rule.String(), State: `cleared`, Time: c.At}
+	if c.Firing {
+		n.State = `firing`
+	}
+	return n
+}
+
+// how long an action gets before it's given up on
+const actionTimeout = 10 * time.Second
+
+// Actions reach someone who isn't watching the terminal, the zero value does nothing.
+type Actions struct {
+	Exec    string // shell command run with the notice in MONET_* environment variables
+	Webhook string // URL the notice is POSTed to as JSON
+	Client  *http.Client
+}
+
+// Run takes every action for a notice, it blocks until they're done (or have timed out).
+func (a Actions) Run(n Notice) error {
+	ctx, cancel := context.WithTimeout(context.Background(), actionTimeout)
+	defer cancel()
+	var errs []error
+	if a.Exec != `` {
+		errs = append(errs, a.exec(ctx, n))
+	}
+	if a.Webhook != `` {
+		errs = append(errs, a.post(ctx, n))
+	}
+	return errors.Join(errs...)
+}
+
+// exec runs the command through the shell, so pipes and friends work
+func (a Actions) exec(ctx context.Context, n Notice) error {
+	cmd := exec.CommandContext(ctx, `/bin/sh`, `-c`, a.Exec)
+	cmd.Env = append(os.Environ(),
+		`MONET_TARGET=`+n.Target,
+		`MONET_ADDRESS=`+n.Address,
+		`MONET_SEVERITY=`+n.Severity,
+		`MONET_RULE=`+n.Rule,
+		`MONET_STATE=`+n.State,
+		`MONET_TIME=`+n.Time.Format(time.RFC3339),
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf(`exec: %w: %s`, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// post sends the notice to the webhook, anything but a 2xx is an error
+func (a Actions) post(ctx context.Context, n Notice) error {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	enc.SetEscapeHTML(false) // rules are full of < and >
+	if err := enc.Encode(n); err != nil {
+		return fmt.Errorf(`webhook: %w`, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.Webhook, &body)
+	if err != nil {
+		return fmt.Errorf(`webhook: %w`, err)
+	}
+	req.Header.Set(`Content-Type`, `application/json`)
+	client := a.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf(`webhook: %w`, err)
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, res.Body) // so the connection can be reused
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf(`webhook: %s`, res.Status)
+	}
+	return nil
+}
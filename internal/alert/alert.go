@@ -0,0 +1,246 @@
+// Package alert decides when a target is worth worrying about.
+//
+// Rules come from a file (see Parse), a line per rule:
+//
+//	# severity condition [for duration] [clear condition [for duration]]
+//	critical loss > 5% over 30s clear loss < 1% over 1m
+//	warning  p95 > 80ms over 1m for 10s clear p95 < 60ms over 1m
+//	critical 3 consecutive losses clear 5 consecutive replies
+//
+// A rule fires once its condition has held for the `for` duration and clears once the clear condition has (or,
+// without one, once the condition no longer holds), so alerts don't flap on the edge of a threshold.
+package alert
+
+import (
+	"slices"
+	"time"
+
+	"github.com/bign8/monet/internal/quality"
+)
+
+// Severity is how bad things are.
+type Severity uint8
+
+const (
+	None     Severity = iota // nothing to see here
+	Warning                  // degraded (yellow)
+	Critical                 // broken (red)
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Warning:
+		return `warning`
+	case Critical:
+		return `critical`
+	}
+	return `ok`
+}
+
+// Sample is a probe that has either been answered or given up on.
+type Sample struct {
+	At   time.Time     // when the probe was sent
+	Rtt  time.Duration // round trip time (zero when Lost)
+	Lost bool
+
+	pending bool // sent, but neither answered nor given up on yet
+}
+
+// how long a probe can go without being answered or given up on before the rules stop waiting for it
+const patience = 5 * time.Second
+
+// Condition is something that does (or doesn't) hold for the samples of a target.
+type Condition interface {
+	Holds(samples []Sample, now time.Time) bool // samples are oldest first
+	String() string
+}
+
+// Rule raises an alert of some severity while its condition holds.
+type Rule struct {
+	Severity Severity
+	Raise    Condition
+	For      time.Duration // how long Raise has to hold before the rule fires
+	Clear    Condition     // nil clears as soon as Raise no longer holds
+	ClearFor time.Duration // how long Clear has to hold before the rule clears
+	text     string
+}
+
+func (r Rule) String() string {
+	if r.text == `` && r.Raise != nil {
+		return r.Raise.String()
+	}
+	return r.text
+}
+
+// clears is true when a firing rule can stand down
+func (r Rule) clears(samples []Sample, now time.Time) bool {
+	if r.Clear == nil {
+		return !r.Raise.Holds(samples, now)
+	}
+	return r.Clear.Holds(samples, now)
+}
+
+// Change is a rule firing or clearing.
+type Change struct {
+	Rule   Rule
+	Firing bool // false when it cleared
+	At     time.Time
+}
+
+// forced is the rule behind Force (for trying things out without breaking the network)
+func forced(s Severity) Rule {
+	return Rule{Severity: s, Raise: byHand{}}
+}
+
+// byHand is the condition of a forced rule, which never holds on its own
+type byHand struct{}
+
+func (byHand) Holds([]Sample, time.Time) bool { return false }
+func (byHand) String() string                 { return `forced from the keyboard` }
+
+// Tracker follows the rules for a single target.
+type Tracker struct {
+	rules   []Rule
+	samples []Sample      // oldest first, only as many as the rules look at (and any still pending)
+	judged  int           // how many samples the rules have seen
+	keep    time.Duration // longest window of any rule (or of the call quality estimate)
+	streak  int           // longest streak of any rule
+	firing  []bool        // per rule
+	since   []time.Time   // per rule, when whatever would flip firing started holding (zero when it isn't)
+	forced  Severity
+
+	log   incidents // stretches of lost or slow probes
+	first time.Time // when the first probe was sent
+}
+
+// NewTracker starts following rules, also keeping track of incidents (stretches of lost probes or replies slower than slow).
+func NewTracker(rules []Rule, slow time.Duration) *Tracker {
+	t := &Tracker{rules: rules, firing: make([]bool, len(rules)), since: make([]time.Time, len(rules)), log: incidents{slow: slow}, keep: quality.Window}
+	for _, r := range rules {
+		for _, c := range []Condition{r.Raise, r.Clear} {
+			switch c := c.(type) {
+			case threshold:
+				t.keep = max(t.keep, c.over)
+			case streak:
+				t.streak = max(t.streak, c.n)
+			}
+		}
+	}
+	return t
+}
+
+// Sent notes a probe going out, so rules wait for it to be answered or given up on before judging anything sent after it.
+func (t *Tracker) Sent(at time.Time) {
+	t.Add(Sample{At: at, pending: true})
+}
+
+// Add remembers what happened to a probe, samples don't have to arrive in order (a loss is only known well after later replies).
+func (t *Tracker) Add(s Sample) {
+	if t.first.IsZero() || s.At.Before(t.first) {
+		t.first = s.At
+	}
+	i := len(t.samples)
+	for i > 0 && t.samples[i-1].At.After(s.At) {
+		i--
+	}
+	if i > 0 && t.samples[i-1].At.Equal(s.At) && t.samples[i-1].pending {
+		t.samples[i-1] = s
+		return
+	}
+	t.samples = slices.Insert(t.samples, i, s)
+	if i < t.judged {
+		t.judged++ // too late to be judged
+	}
+
+	newest := t.samples[len(t.samples)-1].At
+	drop := 0
+	for drop < min(t.judged, len(t.samples)-t.streak) && newest.Sub(t.samples[drop].At) > t.keep {
+		drop++
+	}
+	t.samples, t.judged = t.samples[drop:], t.judged-drop
+}
+
+// Check evaluates every rule, returning the ones that fired or cleared.
+//
+// Probes are judged in the order they were sent, as of when they were sent, once everything sent before them has been
+// answered or given up on. A loss is only known a while after the probe went out (by which time later probes have been
+// answered), so judging as replies arrive would never see "3 consecutive losses".
+func (t *Tracker) Check(now time.Time) []Change {
+	t.samples = slices.DeleteFunc(t.samples, func(s Sample) bool {
+		return s.pending && now.Sub(s.At) > patience
+	})
+
+	var changes []Change
+	for ; t.judged < len(t.samples) && !t.samples[t.judged].pending; t.judged++ {
+		samples := t.samples[:t.judged+1]
+		at := samples[len(samples)-1].At
+		t.log.judge(samples[len(samples)-1])
+		for i, r := range t.rules {
+			holds, wait := r.Raise.Holds(samples, at), r.For
+			if t.firing[i] {
+				holds, wait = r.clears(samples, at), r.ClearFor
+			}
+			if !holds {
+				t.since[i] = time.Time{}
+				continue
+			}
+			if t.since[i].IsZero() {
+				t.since[i] = at
+			}
+			if at.Sub(t.since[i]) < wait {
+				continue
+			}
+			t.firing[i], t.since[i] = !t.firing[i], time.Time{}
+			changes = append(changes, Change{Rule: r, Firing: t.firing[i], At: now})
+		}
+	}
+	return changes
+}
+
+// Force raises (or with None, clears) an alert by hand.
+func (t *Tracker) Force(s Severity, now time.Time) []Change {
+	if s == t.forced {
+		return nil
+	}
+	var changes []Change
+	if t.forced != None {
+		changes = append(changes, Change{Rule: forced(t.forced), At: now})
+	}
+	if t.forced = s; s != None {
+		changes = append(changes, Change{Rule: forced(s), Firing: true, At: now})
+	}
+	return changes
+}
+
+// Firing is the most severe rule currently firing (ok is false when there isn't one).
+func (t *Tracker) Firing() (rule Rule, ok bool) {
+	if t.forced != None {
+		rule, ok = forced(t.forced), true
+	}
+	for i, r := range t.rules {
+		if t.firing[i] && (!ok || r.Severity > rule.Severity) {
+			rule, ok = r, true
+		}
+	}
+	return rule, ok
+}
+
+// Call scores the probes judged within quality.Window of the latest one for a call, ok is false without a reply to go on.
+func (t *Tracker) Call() (s quality.Score, ok bool) {
+	judged := t.samples[:t.judged]
+	if len(judged) == 0 {
+		return s, false
+	}
+	newest := judged[len(judged)-1].At
+	start := len(judged)
+	for start > 0 && newest.Sub(judged[start-1].At) <= quality.Window {
+		start--
+	}
+	return call(judged[start:])
+}
+
+// Severity is how bad things are right now.
+func (t *Tracker) Severity() Severity {
+	r, _ := t.Firing()
+	return r.Severity
+}
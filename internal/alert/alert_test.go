@@ -0,0 +1,213 @@
+package alert
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+// step is something happening to a tracker, returning what changed (checks are the only steps that change anything)
+type step func(t *Tracker) []Change
+
+// at is sec seconds after epoch
+func at(sec int) time.Time { return epoch.Add(time.Duration(sec) * time.Second) }
+
+func sent(secs ...int) (steps []step) {
+	for _, s := range secs {
+		steps = append(steps, func(t *Tracker) []Change { t.Sent(at(s)); return nil })
+	}
+	return steps
+}
+
+func lost(secs ...int) (steps []step) {
+	for _, s := range secs {
+		steps = append(steps, func(t *Tracker) []Change { t.Add(Sample{At: at(s), Lost: true}); return nil })
+	}
+	return steps
+}
+
+func replied(rtt time.Duration, secs ...int) (steps []step) {
+	for _, s := range secs {
+		steps = append(steps, func(t *Tracker) []Change { t.Add(Sample{At: at(s), Rtt: rtt}); return nil })
+	}
+	return steps
+}
+
+func check(sec int) []step {
+	return []step{func(t *Tracker) []Change { return t.Check(at(sec)) }}
+}
+
+// steady is a probe a second from `from` through `to`, each answered in rtt and checked right away
+func steady(from, to int, rtt time.Duration) (steps []step) {
+	for s := from; s <= to; s++ {
+		steps = append(steps, slices.Concat(replied(rtt, s), check(s))...)
+	}
+	return steps
+}
+
+func rules(t *testing.T, text string) []Rule {
+	t.Helper()
+	rules, err := Parse(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rules
+}
+
+func TestTracker(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules string // empty uses Defaults
+		steps []step
+		want  []string // how long after epoch it was checked, severity, fired or cleared and the rule
+	}{
+		{
+			name:  `streak fires, then clears on a streak of its own`,
+			rules: `critical 3 consecutive losses clear 5 consecutive replies`,
+			steps: slices.Concat(
+				lost(0, 1), check(1),
+				lost(2), check(2),
+				steady(3, 6, 20*time.Millisecond), // four replies aren't enough
+				steady(7, 8, 20*time.Millisecond),
+			),
+			want: []string{
+				`2s critical fired 3 consecutive losses clear 5 consecutive replies`,
+				`7s critical cleared 3 consecutive losses clear 5 consecutive replies`,
+			},
+		},
+		{
+			name:  `clears once the condition stops holding without a clear condition`,
+			rules: `warning 2 consecutive losses`,
+			steps: slices.Concat(lost(0, 1), check(1), replied(20*time.Millisecond, 2), check(2)),
+			want: []string{
+				`1s warning fired 2 consecutive losses`,
+				`2s warning cleared 2 consecutive losses`,
+			},
+		},
+		{
+			name:  `hysteresis`,
+			rules: `warning avg > 50ms over 5s clear avg < 30ms over 5s`,
+			steps: slices.Concat(
+				steady(0, 5, 60*time.Millisecond),
+				steady(6, 15, 40*time.Millisecond), // under the threshold, not enough to clear
+				steady(16, 20, 20*time.Millisecond),
+			),
+			want: []string{
+				`0s warning fired avg > 50ms over 5s clear avg < 30ms over 5s`,
+				`19s warning cleared avg > 50ms over 5s clear avg < 30ms over 5s`,
+			},
+		},
+		{
+			name:  `p95 over a window for a while`,
+			rules: `warning p95 > 80ms over 10s for 5s`,
+			steps: slices.Concat(
+				steady(0, 9, 20*time.Millisecond),
+				steady(10, 17, 100*time.Millisecond),
+				steady(18, 30, 20*time.Millisecond),
+			),
+			want: []string{
+				`15s warning fired p95 > 80ms over 10s for 5s`,
+				`28s warning cleared p95 > 80ms over 10s for 5s`,
+			},
+		},
+		{
+			name:  `not for long enough`,
+			rules: `warning avg > 80ms over 1s for 3s`,
+			steps: slices.Concat(
+				steady(0, 2, 100*time.Millisecond),
+				steady(3, 4, 20*time.Millisecond),
+				steady(5, 7, 100*time.Millisecond),
+			),
+		},
+		{
+			name:  `clear for a while`,
+			rules: `critical 2 consecutive losses clear 1 consecutive reply for 2s`,
+			steps: slices.Concat(
+				lost(0, 1), check(1),
+				steady(2, 3, 20*time.Millisecond),
+				lost(4), check(4), // starts waiting all over again
+				steady(5, 7, 20*time.Millisecond),
+			),
+			want: []string{
+				`1s critical fired 2 consecutive losses clear 1 consecutive reply for 2s`,
+				`7s critical cleared 2 consecutive losses clear 1 consecutive reply for 2s`,
+			},
+		},
+		{
+			name:  `losses are judged in the order they were sent`,
+			rules: `critical 3 consecutive losses`,
+			steps: slices.Concat(
+				sent(0, 1, 2, 3),
+				replied(20*time.Millisecond, 3), check(3), // waits on the ones before it
+				lost(0, 1, 2), check(4),
+			),
+			want: []string{
+				`4s critical fired 3 consecutive losses`,
+				`4s critical cleared 3 consecutive losses`,
+			},
+		},
+		{
+			name:  `late loss`,
+			rules: `critical 2 consecutive losses`,
+			steps: slices.Concat(
+				lost(0), check(0),
+				replied(20*time.Millisecond, 2), check(2),
+				lost(1), check(3), // too late, the reply after it was already judged
+			),
+		},
+		{
+			name:  `stops waiting on probes pending too long`,
+			rules: `critical 2 consecutive losses`,
+			steps: slices.Concat(
+				sent(0),
+				lost(1, 2), check(3),
+				check(5), // still patient
+				check(6),
+			),
+			want: []string{
+				`6s critical fired 2 consecutive losses`,
+			},
+		},
+		{
+			name: `defaults`,
+			steps: slices.Concat(
+				steady(0, 4, 20*time.Millisecond),
+				steady(5, 5, 60*time.Millisecond),
+				lost(6), check(6),
+				steady(7, 70, 20*time.Millisecond),
+			),
+			want: []string{
+				`5s warning fired max > 50ms over 1m`,
+				`6s critical fired loss > 0% over 20s`,
+				`27s critical cleared loss > 0% over 20s`,
+				`1m6s warning cleared max > 50ms over 1m`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Defaults
+			if tt.rules != `` {
+				r = rules(t, tt.rules)
+			}
+			tracker := NewTracker(r, 0)
+			var got []string
+			for _, s := range tt.steps {
+				for _, c := range s(tracker) {
+					state := `cleared`
+					if c.Firing {
+						state = `fired`
+					}
+					got = append(got, fmt.Sprintf(`%v %s %s %s`, c.At.Sub(epoch), c.Rule.Severity, state, c.Rule))
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got\n\t%s\nwant\n\t%s", strings.Join(got, "\n\t"), strings.Join(tt.want, "\n\t"))
+			}
+		})
+	}
+}
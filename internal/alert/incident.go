@@ -0,0 +1,72 @@
+package alert
+
+import "time"
+
+// Incident is a stretch of probes that were lost or too slow.
+type Incident struct {
+	Start  time.Time     // when the first bad probe was sent
+	End    time.Time     // when the first good probe after it was sent (zero while ongoing)
+	Probes int           // bad probes (lost or slow)
+	Lost   int           // of which lost
+	Worst  time.Duration // slowest reply (zero when every probe was lost)
+}
+
+// Ongoing is true until things have been calm for a while.
+func (i Incident) Ongoing() bool { return i.End.IsZero() }
+
+// Duration is how long the incident lasted (or has lasted so far).
+func (i Incident) Duration(now time.Time) time.Duration {
+	if i.Ongoing() {
+		return now.Sub(i.Start)
+	}
+	return i.End.Sub(i.Start)
+}
+
+// how many good probes in a row end an incident (so a reply sneaking through an outage doesn't split it in two)
+const calm = 3
+
+// incidents groups bad probes as they're judged
+type incidents struct {
+	slow   time.Duration // replies slower than this are bad (0 = only losses are)
+	list   []Incident    // oldest first, the last might be ongoing
+	good   int           // good probes in a row since the last bad one
+	recess time.Time     // when the first of those was sent
+}
+
+func (in *incidents) judge(s Sample) {
+	bad := s.Lost || in.slow > 0 && s.Rtt > in.slow
+	n := len(in.list)
+	ongoing := n > 0 && in.list[n-1].Ongoing()
+	switch {
+	case !bad && ongoing:
+		if in.good == 0 {
+			in.recess = s.At
+		}
+		if in.good++; in.good >= calm {
+			in.list[n-1].End = in.recess
+		}
+		return
+	case !bad:
+		return
+	case !ongoing:
+		in.list = append(in.list, Incident{Start: s.At})
+		n++
+	}
+	in.good = 0
+	i := &in.list[n-1]
+	i.Probes++
+	if s.Lost {
+		i.Lost++
+	}
+	i.Worst = max(i.Worst, s.Rtt)
+}
+
+// Incidents is every incident so far, oldest first.
+func (t *Tracker) Incidents() []Incident {
+	return t.log.list
+}
+
+// Since is when the first probe was sent (zero before then).
+func (t *Tracker) Since() time.Time {
+	return t.first
+}
@@ -0,0 +1,303 @@
+package alert
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bign8/monet/internal/quality"
+)
+
+// Defaults are the rules without a rules file (what monet did before it had rules).
+var Defaults = func() []Rule {
+	rules, err := Parse(strings.NewReader(defaults))
+	if err != nil {
+		panic(err)
+	}
+	return rules
+}()
+
+const defaults = `
+critical loss > 0% over 20s
+critical max > 90ms over 1m
+warning  max > 50ms over 1m
+`
+
+// CallQuality are rules going by how a call would fare instead of by latency: fair calls warn, poor ones are critical.
+var CallQuality = func() []Rule {
+	rules, err := Parse(strings.NewReader(callQuality))
+	if err != nil {
+		panic(err)
+	}
+	return rules
+}()
+
+const callQuality = `
+critical r < 60 over 30s for 5s clear r > 62 over 30s
+warning  r < 80 over 30s for 5s clear r > 82 over 30s
+`
+
+// Load reads rules from a file.
+func Load(path string) ([]Rule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	rules, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf(`%s:%w`, path, err)
+	}
+	return rules, nil
+}
+
+// Parse reads a rule per line, blank lines and anything after a # are ignored.
+//
+// Conditions are either a statistic of the probes sent within a window or a streak of the latest probes:
+//
+//	loss > 5% over 30s         (share of probes lost)
+//	avg > 50ms over 1m         (also min, max, jitter and percentiles like p50, p95 or p99.9)
+//	mos < 3.6 over 30s         (call quality, also r for the R-factor behind it, see package quality)
+//	3 consecutive losses       (or replies)
+func Parse(r io.Reader) ([]Rule, error) {
+	var rules []Rule
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line, _, _ := strings.Cut(scanner.Text(), `#`)
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		rule, err := parseRule(fields)
+		if err != nil {
+			return nil, fmt.Errorf(`%d: %w`, n, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+func parseRule(fields []string) (r Rule, err error) {
+	switch fields[0] {
+	case `warning`:
+		r.Severity = Warning
+	case `critical`:
+		r.Severity = Critical
+	default:
+		return r, fmt.Errorf(`unknown severity %q (warning or critical)`, fields[0])
+	}
+	r.text = strings.Join(fields[1:], ` `)
+
+	raise, rest := fields[1:], []string(nil)
+	if i := slices.Index(raise, `clear`); i >= 0 {
+		raise, rest = raise[:i], raise[i+1:]
+		if len(rest) == 0 {
+			return r, fmt.Errorf(`nothing to clear on`)
+		}
+	}
+	if r.Raise, r.For, err = parseCondition(raise); err != nil {
+		return r, err
+	}
+	if rest != nil {
+		r.Clear, r.ClearFor, err = parseCondition(rest)
+	}
+	return r, err
+}
+
+// parseCondition parses a condition and the optional `for` after it
+func parseCondition(fields []string) (c Condition, wait time.Duration, err error) {
+	if n := len(fields); n >= 2 && fields[n-2] == `for` {
+		if wait, err = time.ParseDuration(fields[n-1]); err != nil {
+			return nil, 0, fmt.Errorf(`for: %w`, err)
+		}
+		fields = fields[:n-2]
+	}
+	text := strings.Join(fields, ` `)
+
+	if len(fields) == 3 && fields[1] == `consecutive` {
+		n, err := strconv.Atoi(fields[0])
+		if err != nil || n < 1 {
+			return nil, 0, fmt.Errorf(`%q: bad streak %q`, text, fields[0])
+		}
+		switch fields[2] {
+		case `losses`, `loss`:
+			return streak{n: n, lost: true, text: text}, wait, nil
+		case `replies`, `reply`:
+			return streak{n: n, text: text}, wait, nil
+		}
+		return nil, 0, fmt.Errorf(`%q: consecutive losses or replies, not %s`, text, fields[2])
+	}
+
+	if len(fields) != 5 || fields[3] != `over` {
+		return nil, 0, fmt.Errorf(`%q: expected "<metric> <op> <value> over <duration>" or "<n> consecutive losses"`, text)
+	}
+	t := threshold{metric: fields[0], text: text}
+	switch fields[1] {
+	case `>`:
+		t.above = true
+	case `<`:
+	default:
+		return nil, 0, fmt.Errorf(`%q: unknown operator %q (> or <)`, text, fields[1])
+	}
+	if t.over, err = time.ParseDuration(fields[4]); err != nil || t.over <= 0 {
+		return nil, 0, fmt.Errorf(`%q: bad window %q`, text, fields[4])
+	}
+
+	value := fields[2]
+	switch {
+	case t.metric == `loss`:
+		percent, ok := strings.CutSuffix(value, `%`)
+		v, err := strconv.ParseFloat(percent, 64)
+		if !ok || err != nil {
+			return nil, 0, fmt.Errorf(`%q: loss is a percentage, not %q`, text, value)
+		}
+		t.value = v / 100
+		return t, wait, nil
+	case t.metric == `mos`, t.metric == `r`:
+		if t.value, err = strconv.ParseFloat(value, 64); err != nil {
+			return nil, 0, fmt.Errorf(`%q: %s is a number, not %q`, text, t.metric, value)
+		}
+		return t, wait, nil
+	case t.metric == `avg`, t.metric == `min`, t.metric == `max`, t.metric == `jitter`:
+	case strings.HasPrefix(t.metric, `p`):
+		q, err := strconv.ParseFloat(t.metric[1:], 64)
+		if err != nil || q <= 0 || q >= 100 {
+			return nil, 0, fmt.Errorf(`%q: bad percentile %q`, text, t.metric)
+		}
+		t.q = q / 100
+	default:
+		return nil, 0, fmt.Errorf(`%q: unknown metric %q (loss, avg, min, max, jitter, mos, r or a percentile like p95)`, text, t.metric)
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return nil, 0, fmt.Errorf(`%q: %s is a duration, not %q`, text, t.metric, value)
+	}
+	t.value = float64(d)
+	return t, wait, nil
+}
+
+// threshold compares a statistic of the probes sent within a window
+type threshold struct {
+	metric string        // loss, avg, min, max, jitter, mos, r or a percentile
+	q      float64       // which percentile (0-1)
+	above  bool          // > (otherwise <)
+	value  float64       // a ratio for loss, a score for mos and r, nanoseconds otherwise
+	over   time.Duration // window
+	text   string
+}
+
+func (t threshold) String() string { return t.text }
+
+func (t threshold) Holds(samples []Sample, now time.Time) bool {
+	start := len(samples)
+	for start > 0 && now.Sub(samples[start-1].At) <= t.over {
+		start--
+	}
+	window := samples[start:]
+
+	var v float64
+	switch t.metric {
+	case `loss`:
+		if len(window) == 0 {
+			return false
+		}
+		v = loss(window)
+	case `mos`, `r`:
+		score, ok := call(window)
+		if !ok {
+			return false
+		}
+		v = score.MOS
+		if t.metric == `r` {
+			v = score.R
+		}
+	default:
+		rtts := replies(window)
+		if len(rtts) == 0 {
+			return false
+		}
+		switch t.metric {
+		case `avg`:
+			v = float64(mean(rtts))
+		case `min`:
+			v = float64(slices.Min(rtts))
+		case `max`:
+			v = float64(slices.Max(rtts))
+		case `jitter`:
+			v = float64(quality.Jitter(rtts))
+		default:
+			slices.Sort(rtts)
+			v = float64(rtts[max(int(math.Ceil(t.q*float64(len(rtts))))-1, 0)]) // nearest rank
+		}
+	}
+	if t.above {
+		return v > t.value
+	}
+	return v < t.value
+}
+
+// loss is the share of samples lost
+func loss(samples []Sample) float64 {
+	lost := 0
+	for _, s := range samples {
+		if s.Lost {
+			lost++
+		}
+	}
+	return float64(lost) / float64(len(samples))
+}
+
+// replies are the round trips of the samples that weren't lost (in the order they were sent)
+func replies(samples []Sample) []time.Duration {
+	var rtts []time.Duration
+	for _, s := range samples {
+		if !s.Lost {
+			rtts = append(rtts, s.Rtt)
+		}
+	}
+	return rtts
+}
+
+func mean(rtts []time.Duration) time.Duration {
+	var sum float64
+	for _, r := range rtts {
+		sum += float64(r)
+	}
+	return time.Duration(sum / float64(len(rtts)))
+}
+
+// call scores the samples for a call, ok is false without a reply to go on
+func call(samples []Sample) (s quality.Score, ok bool) {
+	rtts := replies(samples)
+	if len(rtts) == 0 {
+		return s, false
+	}
+	return quality.Estimate(mean(rtts), quality.Jitter(rtts), loss(samples)), true
+}
+
+// streak is the latest n probes all being lost (or answered)
+type streak struct {
+	n    int
+	lost bool
+	text string
+}
+
+func (s streak) String() string { return s.text }
+
+func (s streak) Holds(samples []Sample, _ time.Time) bool {
+	if len(samples) < s.n {
+		return false
+	}
+	for _, sample := range samples[len(samples)-s.n:] {
+		if sample.Lost != s.lost {
+			return false
+		}
+	}
+	return true
+}
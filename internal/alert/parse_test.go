@@ -0,0 +1,143 @@
+package alert
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		line string
+		want Rule
+		err  string // part of the error, empty when the line is fine
+	}{
+		{
+			line: `critical loss > 5% over 30s clear loss < 1% over 1m`,
+			want: Rule{
+				Severity: Critical,
+				Raise:    threshold{metric: `loss`, above: true, value: .05, over: 30 * time.Second, text: `loss > 5% over 30s`},
+				Clear:    threshold{metric: `loss`, value: .01, over: time.Minute, text: `loss < 1% over 1m`},
+				text:     `loss > 5% over 30s clear loss < 1% over 1m`,
+			},
+		},
+		{
+			line: `warning  p95 > 80ms over 1m for 10s clear p95 < 60ms over 1m for 30s  # comments are ignored`,
+			want: Rule{
+				Severity: Warning,
+				Raise:    threshold{metric: `p95`, q: .95, above: true, value: float64(80 * time.Millisecond), over: time.Minute, text: `p95 > 80ms over 1m`},
+				For:      10 * time.Second,
+				Clear:    threshold{metric: `p95`, q: .95, value: float64(60 * time.Millisecond), over: time.Minute, text: `p95 < 60ms over 1m`},
+				ClearFor: 30 * time.Second,
+				text:     `p95 > 80ms over 1m for 10s clear p95 < 60ms over 1m for 30s`,
+			},
+		},
+		{
+			line: `critical 3 consecutive losses clear 5 consecutive replies`,
+			want: Rule{
+				Severity: Critical,
+				Raise:    streak{n: 3, lost: true, text: `3 consecutive losses`},
+				Clear:    streak{n: 5, text: `5 consecutive replies`},
+				text:     `3 consecutive losses clear 5 consecutive replies`,
+			},
+		},
+		{
+			line: `warning 1 consecutive loss clear 1 consecutive reply`,
+			want: Rule{
+				Severity: Warning,
+				Raise:    streak{n: 1, lost: true, text: `1 consecutive loss`},
+				Clear:    streak{n: 1, text: `1 consecutive reply`},
+				text:     `1 consecutive loss clear 1 consecutive reply`,
+			},
+		},
+		{
+			line: `warning p99.5 > 200ms over 5m`,
+			want: Rule{Severity: Warning, Raise: threshold{metric: `p99.5`, q: .995, above: true, value: float64(200 * time.Millisecond), over: 5 * time.Minute, text: `p99.5 > 200ms over 5m`}, text: `p99.5 > 200ms over 5m`},
+		},
+		{
+			line: `warning mos < 3.6 over 30s`,
+			want: Rule{Severity: Warning, Raise: threshold{metric: `mos`, value: 3.6, over: 30 * time.Second, text: `mos < 3.6 over 30s`}, text: `mos < 3.6 over 30s`},
+		},
+		{
+			line: `critical r < 60 over 30s for 5s`,
+			want: Rule{Severity: Critical, Raise: threshold{metric: `r`, value: 60, over: 30 * time.Second, text: `r < 60 over 30s`}, For: 5 * time.Second, text: `r < 60 over 30s for 5s`},
+		},
+		{
+			line: `warning jitter > 30ms over 1m`,
+			want: Rule{Severity: Warning, Raise: threshold{metric: `jitter`, above: true, value: float64(30 * time.Millisecond), over: time.Minute, text: `jitter > 30ms over 1m`}, text: `jitter > 30ms over 1m`},
+		},
+
+		{line: `info loss > 5% over 30s`, err: `unknown severity "info"`},
+		{line: `warning loss > 5% over 30s clear`, err: `nothing to clear on`},
+		{line: `warning loss > 5% over 30s for a while`, err: `expected "<metric> <op> <value> over <duration>"`},
+		{line: `warning loss > 5% over 30s for soon`, err: `for: time: invalid duration "soon"`},
+		{line: `warning loss > 5% over 30s clear loss < 1% over 1m for soon`, err: `for: time: invalid duration "soon"`},
+		{line: `critical few consecutive losses`, err: `bad streak "few"`},
+		{line: `critical 0 consecutive losses`, err: `bad streak "0"`},
+		{line: `critical 3 consecutive timeouts`, err: `consecutive losses or replies, not timeouts`},
+		{line: `critical loss > 5%`, err: `"loss > 5%": expected "<metric> <op> <value> over <duration>" or "<n> consecutive losses"`},
+		{line: `critical loss > 5% during 30s`, err: `expected "<metric> <op> <value> over <duration>"`},
+		{line: `critical loss >= 5% over 30s`, err: `unknown operator ">=" (> or <)`},
+		{line: `critical loss > 5% over forever`, err: `bad window "forever"`},
+		{line: `critical loss > 5% over 0s`, err: `bad window "0s"`},
+		{line: `critical loss > 5 over 30s`, err: `loss is a percentage, not "5"`},
+		{line: `critical loss > lots% over 30s`, err: `loss is a percentage, not "lots%"`},
+		{line: `warning mos < good over 30s`, err: `mos is a number, not "good"`},
+		{line: `warning r < 60% over 30s`, err: `r is a number, not "60%"`},
+		{line: `warning p100 > 1s over 1m`, err: `bad percentile "p100"`},
+		{line: `warning p0 > 1s over 1m`, err: `bad percentile "p0"`},
+		{line: `warning pretty > 1s over 1m`, err: `bad percentile "pretty"`},
+		{line: `warning rtt > 1s over 1m`, err: `unknown metric "rtt"`},
+		{line: `warning avg > 50 over 1m`, err: `avg is a duration, not "50"`},
+		{line: `warning avg > 50ms over 1m clear avg < 40 over 1m`, err: `avg is a duration, not "40"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			rules, err := Parse(strings.NewReader(tt.line))
+			if tt.err != `` {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf(`error %v, want one with %q`, err, tt.err)
+				}
+				if !strings.HasPrefix(err.Error(), `1: `) {
+					t.Errorf(`error %q doesn't say which line`, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rules) != 1 || !reflect.DeepEqual(rules[0], tt.want) {
+				t.Errorf("got  %#v\nwant %#v", rules, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), `rules.txt`)
+	contents := "# mostly defaults\n\ncritical loss > 0% over 20s\n  # indented comment\nwarning max > 50ms over 1m\ncritical max > 90ms\n"
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil || !strings.HasPrefix(err.Error(), path+`:6: "max > 90ms": expected`) {
+		t.Errorf(`error %v, want one pointing at line 6 of %s`, err, path)
+	}
+
+	if err := os.WriteFile(path, []byte(strings.TrimSuffix(contents, "critical max > 90ms\n")), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 || rules[0].String() != `loss > 0% over 20s` || rules[1].String() != `max > 50ms over 1m` {
+		t.Errorf(`rules = %v, want the two on lines 3 and 5`, rules)
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), `missing.txt`)); !os.IsNotExist(err) {
+		t.Errorf(`error %v, want not exist`, err)
+	}
+}
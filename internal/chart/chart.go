@@ -0,0 +1,246 @@
+// Package chart draws monet's line chart.
+//
+// It started life as asciigraph's PlotMany, but a ping can be in more states than a float64 can tell apart:
+// a reply, a reply too slow to fit on the chart, no reply yet and no reply ever.
+package chart
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Point is a single column of a line.
+type Point struct {
+	Value   float64 // where the point sits on the y-axis (ignored when Lost or Pending)
+	Clipped bool    // the real value didn't fit, Value is as high as it goes (drawn with an arrow)
+	Lost    bool    // no reply is coming (drawn as a red column)
+	Pending bool    // no reply yet (drawn as a dim marker)
+	Odd     bool    // a reply came late, twice or out of order (drawn as a diamond at the bottom, under the line)
+}
+
+// Line is a series of points drawn in a single color.
+type Line struct {
+	Points []Point
+	Color  lipgloss.Color
+	Legend string
+}
+
+// Flat is a line sitting at value across width columns (for reference lines like the average).
+func Flat(value float64, width int, color lipgloss.Color, legend string) Line {
+	points := make([]Point, width)
+	for i := range points {
+		points[i].Value = value
+	}
+	return Line{Points: points, Color: color, Legend: legend}
+}
+
+// Chart is a line chart with a y-axis on the left, an x-axis, a caption and a legend below.
+type Chart struct {
+	Lines    []Line  // later lines are drawn on top of earlier ones
+	Min, Max float64 // y-axis bounds
+	Height   int     // rows of the y-axis (minus one, the same as asciigraph)
+	Caption  string
+
+	Labels []Label // x-axis labels (ones that would overlap their neighbor on the left are skipped)
+	Marks  []int   // columns where something changed (drawn as a faint dotted column behind the lines)
+}
+
+// Label is text on the x-axis starting at a column.
+type Label struct {
+	Column int
+	Text   string
+}
+
+// LabelWidth is how many characters every y-axis label takes up (so the chart doesn't shift as the data changes).
+const LabelWidth = 5
+
+// Margin is the number of columns to the left of the first point (padding, label, padding, axis).
+const Margin = 1 + LabelWidth + 1 + 1
+
+// red marks lost probes
+const red = lipgloss.Color(`9`)
+
+// cell is a single character on screen
+type cell struct {
+	r     rune
+	color lipgloss.Color // empty = terminal default
+	faint bool
+}
+
+// Render draws the chart, it is Height+5 lines tall: Height+1 rows, the x-axis, the caption, a blank line and the legend.
+func (c Chart) Render() string {
+	width := 0
+	for _, l := range c.Lines {
+		width = max(width, len(l.Points))
+	}
+	rows := c.Height + 1
+	grid := make([][]cell, rows)
+	for y := range grid {
+		grid[y] = make([]cell, width)
+		for x := range grid[y] {
+			grid[y][x].r = ' '
+		}
+	}
+
+	interval := c.Max - c.Min
+	if interval <= 0 {
+		interval = 1
+	}
+	// row of a value, counting from the bottom
+	row := func(v float64) int {
+		return min(max(int(math.Round((v-c.Min)/interval*float64(c.Height))), 0), c.Height)
+	}
+	set := func(x, y int, r rune, color lipgloss.Color, faint bool) {
+		grid[c.Height-y][x] = cell{r: r, color: color, faint: faint}
+	}
+
+	for _, x := range c.Marks {
+		if x >= 0 && x < width {
+			for y := 0; y < rows; y++ {
+				set(x, y, '┊', ``, true)
+			}
+		}
+	}
+
+	// lost probes go down first, so the lines that did make it are drawn over them
+	for _, l := range c.Lines {
+		for x, p := range l.Points {
+			if p.Lost {
+				for y := 0; y < rows; y++ {
+					set(x, y, '░', red, false)
+				}
+			}
+		}
+	}
+	for _, l := range c.Lines {
+		for x, p := range l.Points {
+			if p.Odd {
+				set(x, 0, '◆', l.Color, false)
+			}
+		}
+	}
+
+	for _, l := range c.Lines {
+		prev := -1 // row of the previous point (-1 if it wasn't drawn)
+		for x, p := range l.Points {
+			switch {
+			case p.Lost || math.IsNaN(p.Value) && !p.Pending:
+				prev = -1
+				continue
+			case p.Pending:
+				set(x, 0, '·', l.Color, true)
+				prev = -1
+				continue
+			}
+
+			y := row(p.Value)
+			switch {
+			case prev < 0 || prev == y:
+				set(x, y, '─', l.Color, false)
+			case prev < y:
+				set(x, prev, '╯', l.Color, false)
+				set(x, y, '╭', l.Color, false)
+			default:
+				set(x, prev, '╮', l.Color, false)
+				set(x, y, '╰', l.Color, false)
+			}
+			for between := min(prev, y) + 1; prev >= 0 && between < max(prev, y); between++ {
+				set(x, between, '│', l.Color, false)
+			}
+			if p.Clipped {
+				set(x, y, '↑', l.Color, false)
+			}
+			prev = y
+		}
+	}
+
+	var b strings.Builder
+	for i, cells := range grid {
+		label := c.Max - float64(i)/float64(c.Height)*interval
+		fmt.Fprintf(&b, ` %*s ┤`, LabelWidth, short(label))
+		b.WriteString(render(cells))
+		b.WriteString("\n")
+	}
+
+	b.WriteString(axis(c.Labels, width))
+	b.WriteString("\n")
+
+	b.WriteString(center(c.Caption, width, utf8.RuneCountInString(c.Caption)))
+	b.WriteString("\n\n")
+
+	items := make([]string, 0, len(c.Lines))
+	length := 0
+	for _, l := range c.Lines {
+		if l.Legend == `` {
+			continue
+		}
+		items = append(items, lipgloss.NewStyle().Foreground(l.Color).Render(`■`)+` `+l.Legend)
+		length += 2 + utf8.RuneCountInString(l.Legend)
+	}
+	const gap = `   `
+	length += len(gap) * max(len(items)-1, 0)
+	b.WriteString(center(strings.Join(items, gap), width, length))
+	return b.String()
+}
+
+// short formats a y-axis label in at most LabelWidth characters: dropping the decimal first, then thousands (k, M, G)
+func short(v float64) string {
+	s := fmt.Sprintf(`%.1f`, v)
+	if len(s) > LabelWidth {
+		s = fmt.Sprintf(`%.0f`, v)
+	}
+	for _, suffix := range []string{`k`, `M`, `G`} {
+		if len(s) <= LabelWidth {
+			break
+		}
+		v /= 1000
+		s = fmt.Sprintf(`%.0f%s`, v, suffix)
+	}
+	return s
+}
+
+// axis lays the labels out under the plot area
+func axis(labels []Label, width int) string {
+	line := []rune(strings.Repeat(` `, Margin+width))
+	next := 0 // first column free of the previous label
+	for _, l := range labels {
+		text := []rune(l.Text)
+		if l.Column < next || l.Column < 0 || l.Column+len(text) > width {
+			continue
+		}
+		copy(line[Margin+l.Column:], text)
+		next = l.Column + len(text) + 1
+	}
+	return strings.TrimRight(string(line), ` `)
+}
+
+// center pads s (which is length characters wide on screen) to the middle of the plot area
+func center(s string, width, length int) string {
+	return strings.Repeat(` `, Margin+max(width-length, 0)/2) + s
+}
+
+// render styles a row of cells, grouping neighbors that look the same to keep the escape codes down
+func render(cells []cell) string {
+	var b strings.Builder
+	for start := 0; start < len(cells); {
+		end := start + 1
+		for end < len(cells) && cells[end].color == cells[start].color && cells[end].faint == cells[start].faint {
+			end++
+		}
+		run := make([]rune, 0, end-start)
+		for _, c := range cells[start:end] {
+			run = append(run, c.r)
+		}
+		if cells[start].color == `` && !cells[start].faint {
+			b.WriteString(string(run))
+		} else {
+			b.WriteString(lipgloss.NewStyle().Foreground(cells[start].color).Faint(cells[start].faint).Render(string(run)))
+		}
+		start = end
+	}
+	return b.String()
+}
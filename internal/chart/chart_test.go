@@ -0,0 +1,91 @@
+package chart
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestShort(t *testing.T) {
+	tests := []struct {
+		v    float64
+		want string
+	}{
+		{0, `0.0`},
+		{12.34, `12.3`},
+		{999.9, `999.9`},
+		{999.96, `1000`},
+		{1234.5, `1234`},
+		{99999, `99999`},
+		{123456, `123k`},
+		{98765432, `99M`},
+		{123456789, `123M`},
+		{-12.5, `-12.5`},
+		{-1234.5, `-1234`},
+	}
+	for _, tt := range tests {
+		if got := short(tt.v); got != tt.want {
+			t.Errorf(`short(%v) = %q, want %q`, tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestRenderLabels(t *testing.T) {
+	tests := []struct {
+		name     string
+		min, max float64
+	}{
+		{`milliseconds`, 0, 95},
+		{`seconds`, 0, 2500},
+		{`minutes`, 0, 180000},
+	}
+	for _, tt := range tests {
+		plot := Chart{Lines: []Line{Flat(tt.min, 10, ``, `flat`)}, Min: tt.min, Max: tt.max, Height: 4}.Render()
+		rows := strings.Split(plot, "\n")[:5]
+		for _, row := range rows {
+			if !strings.HasPrefix(row[Margin-1:], `┤`) {
+				t.Errorf(`%s: label overflows the margin: %q`, tt.name, row)
+			}
+		}
+	}
+}
+
+func TestRenderMarkers(t *testing.T) {
+	points := []Point{
+		{Value: 1},
+		{Lost: true},
+		{Pending: true},
+		{Value: 10, Clipped: true},
+		{Value: 5, Odd: true},
+		{Value: 5},
+	}
+	plot := Chart{Lines: []Line{{Points: points}}, Min: 0, Max: 10, Height: 4}.Render()
+	var rows [][]rune // plot area only, top row first
+	for _, row := range strings.Split(plot, "\n")[:5] {
+		rows = append(rows, []rune(row)[Margin:])
+	}
+	at := func(x, y int) rune { return rows[len(rows)-1-y][x] } // y counts from the bottom
+
+	tests := []struct {
+		name string
+		x, y int
+		want rune
+	}{
+		{`reply`, 0, 0, '─'},
+		{`pending`, 2, 0, '·'},
+		{`clipped`, 3, 4, '↑'},
+		{`odd`, 4, 0, '◆'},
+		{`odd reply still drawn`, 4, 2, '╰'},
+		{`nothing under a reply`, 5, 0, ' '},
+		{`lost (bottom)`, 1, 0, '░'},
+		{`lost (middle)`, 1, 2, '░'},
+		{`lost (top)`, 1, 4, '░'},
+	}
+	for _, tt := range tests {
+		if got := at(tt.x, tt.y); got != tt.want {
+			t.Errorf(`%s: column %d, row %d is %q, want %q`, tt.name, tt.x, tt.y, got, tt.want)
+		}
+	}
+	if t.Failed() {
+		t.Log("\n" + plot)
+	}
+}
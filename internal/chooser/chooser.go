@@ -5,19 +5,23 @@ import (
 	"encoding/json"
 	"fmt"
 	"maps"
+	"net"
+	"net/url"
 	"slices"
 	"sort"
 	"strings"
 	"time"
 
+	"github.com/bign8/monet/internal/probe"
 	tea "github.com/charmbracelet/bubbletea"
-	probing "github.com/prometheus-community/pro-bing"
 )
 
 //go:embed providers.json
 var providersJSON []byte
 
-func New() *Chooser {
+// New creates a chooser that pings every known provider, or when query is set,
+// asks each of them to resolve query (which resolver actually resolves fastest).
+func New(query string) *Chooser {
 	var providers map[string][]string
 	if err := json.Unmarshal(providersJSON, &providers); err != nil {
 		panic(`invalid json providers: ` + err.Error())
@@ -53,6 +57,7 @@ func New() *Chooser {
 		ownerPad: ownerPad,
 		ipPad:    ipPad,
 		workers:  10,
+		query:    query,
 	}
 }
 
@@ -64,7 +69,8 @@ type Chooser struct {
 	ownerPad int
 	ipPad    int
 	workers  int
-	sortMode uint8 // 0 = name, 1 = duration, moar?
+	query    string // name to resolve (empty = ping)
+	sortMode uint8  // 0 = name, 1 = duration, moar?
 	quitting bool
 }
 
@@ -99,7 +105,7 @@ func (m *Chooser) Init() tea.Cmd {
 type pingResult struct {
 	index int
 	err   error
-	stats *probing.Statistics
+	avg   time.Duration
 }
 
 func (m *Chooser) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -110,23 +116,30 @@ func (m *Chooser) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 		m.table[msg].status = "........."
-		pinger := probing.New(m.table[msg].ip)
-		pinger.Count = 3
-		pinger.Interval = time.Millisecond * 50
-		pinger.Timeout = time.Second
+		target := m.table[msg].ip
+		if m.query != `` {
+			target = `dns://` + net.JoinHostPort(target, `53`) + `?name=` + url.QueryEscape(m.query)
+		}
+		pinger, err := probe.New(target)
+		if err != nil {
+			return m, func() tea.Msg {
+				return pingResult{index: int(msg), err: err}
+			}
+		}
+		pinger.SetInterval(time.Millisecond * 50)
 		return m, func() tea.Msg {
-			err := pinger.Run()
+			avg, err := probe.Sample(pinger, 3, time.Second)
 			return pingResult{
 				index: int(msg),
 				err:   err,
-				stats: pinger.Statistics(),
+				avg:   avg,
 			}
 		}
 	case pingResult:
 		if msg.err != nil {
 			m.table[msg.index].status = msg.err.Error()
 		} else {
-			d := msg.stats.AvgRtt.Round(time.Microsecond)
+			d := msg.avg.Round(time.Microsecond)
 			m.table[msg.index].duration = d
 			m.table[msg.index].status = fmt.Sprintf(`%.3fms`, d.Seconds()*1000)
 		}
@@ -0,0 +1,178 @@
+// Package metrics exposes what monet sees in the Prometheus text format, so long-term dashboards can scrape a running monet.
+//
+// The format is simple enough to write by hand (https://prometheus.io/docs/instrumenting/exposition_formats/),
+// which beats pulling in client_golang and its dependencies for a handful of counters.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Buckets are the upper bounds (in seconds) of the round trip time histogram.
+var Buckets = []float64{.001, .0025, .005, .01, .025, .05, .075, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Metrics counts what happens to the probes of every target, it is safe to use from many goroutines.
+//
+// Methods on a nil *Metrics do nothing, so callers don't need to care whether metrics were asked for.
+type Metrics struct {
+	mu      sync.Mutex
+	targets map[string]*target
+}
+
+type target struct {
+	sent, received, failed, lost uint64
+	buckets                      []uint64 // not cumulative (one count per bucket, plus +Inf)
+	sum                          float64  // seconds
+	severity                     int      // of the most severe alert firing (0 when none are)
+}
+
+// New creates an empty set of metrics.
+func New() *Metrics {
+	return &Metrics{targets: map[string]*target{}}
+}
+
+// get finds (or creates) a target, the lock must be held.
+func (m *Metrics) get(name string) *target {
+	t, ok := m.targets[name]
+	if !ok {
+		t = &target{buckets: make([]uint64, len(Buckets)+1)}
+		m.targets[name] = t
+	}
+	return t
+}
+
+// update runs fn against a target under the lock.
+func (m *Metrics) update(name string, fn func(t *target)) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fn(m.get(name))
+}
+
+// Sent counts a probe going out.
+func (m *Metrics) Sent(name string) {
+	m.update(name, func(t *target) { t.sent++ })
+}
+
+// Received counts a reply and its round trip time.
+func (m *Metrics) Received(name string, rtt time.Duration) {
+	m.update(name, func(t *target) {
+		t.received++
+		t.sum += rtt.Seconds()
+		i, _ := slices.BinarySearch(Buckets, rtt.Seconds())
+		t.buckets[i]++
+	})
+}
+
+// Failed counts a probe that went wrong.
+func (m *Metrics) Failed(name string) {
+	m.update(name, func(t *target) { t.failed++ })
+}
+
+// Lost counts a probe that didn't come back in time.
+func (m *Metrics) Lost(name string) {
+	m.update(name, func(t *target) { t.lost++ })
+}
+
+// Alert sets the severity of the most severe alert firing for a target (0 when none are).
+func (m *Metrics) Alert(name string, severity int) {
+	m.update(name, func(t *target) { t.severity = severity })
+}
+
+// ServeHTTP writes every metric in the Prometheus text format (or OpenMetrics, when the scraper prefers it).
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	open := strings.Contains(r.Header.Get(`Accept`), `application/openmetrics-text`)
+	if open {
+		w.Header().Set(`Content-Type`, `application/openmetrics-text; version=1.0.0; charset=utf-8`)
+	} else {
+		w.Header().Set(`Content-Type`, `text/plain; version=0.0.4; charset=utf-8`)
+	}
+	m.write(w, open)
+}
+
+func (m *Metrics) write(w io.Writer, open bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.targets))
+	for name := range m.targets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	// OpenMetrics names a counter without its _total suffix in the metadata
+	counter := func(name, help string, value func(t *target) uint64) {
+		family := name
+		if open {
+			family = strings.TrimSuffix(name, `_total`)
+		}
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", family, help, family)
+		for _, n := range names {
+			fmt.Fprintf(w, "%s{target=%s} %d\n", name, quote(n), value(m.targets[n]))
+		}
+	}
+	gauge := func(name, help string, value func(t *target) float64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+		for _, n := range names {
+			fmt.Fprintf(w, "%s{target=%s} %s\n", name, quote(n), format(value(m.targets[n])))
+		}
+	}
+
+	counter(`monet_probes_sent_total`, `Probes sent.`, func(t *target) uint64 { return t.sent })
+	counter(`monet_probes_received_total`, `Replies received.`, func(t *target) uint64 { return t.received })
+	counter(`monet_probes_failed_total`, `Probes that failed to send or were answered with an error.`, func(t *target) uint64 { return t.failed })
+	counter(`monet_probes_lost_total`, `Probes without a reply after a second.`, func(t *target) uint64 { return t.lost })
+	gauge(`monet_loss_ratio`, `Lost probes over sent probes since starting.`, func(t *target) float64 {
+		if t.sent == 0 {
+			return 0
+		}
+		return float64(t.lost) / float64(t.sent)
+	})
+	gauge(`monet_warning`, `1 while any alert is firing for a target.`, func(t *target) float64 {
+		if t.severity > 0 {
+			return 1
+		}
+		return 0
+	})
+	gauge(`monet_alert_severity`, `Severity of the most severe alert firing for a target (0 ok, 1 warning, 2 critical).`, func(t *target) float64 {
+		return float64(t.severity)
+	})
+
+	fmt.Fprint(w, "# HELP monet_rtt_seconds Round trip time of replies.\n# TYPE monet_rtt_seconds histogram\n")
+	for _, n := range names {
+		t := m.targets[n]
+		total := uint64(0)
+		for i, count := range t.buckets {
+			total += count
+			le := `+Inf`
+			if i < len(Buckets) {
+				le = format(Buckets[i])
+			}
+			fmt.Fprintf(w, "monet_rtt_seconds_bucket{target=%s,le=%q} %d\n", quote(n), le, total)
+		}
+		fmt.Fprintf(w, "monet_rtt_seconds_sum{target=%s} %s\n", quote(n), format(t.sum))
+		fmt.Fprintf(w, "monet_rtt_seconds_count{target=%s} %d\n", quote(n), total)
+	}
+
+	if open {
+		fmt.Fprint(w, "# EOF\n")
+	}
+}
+
+// quote escapes a label value (backslashes, quotes and newlines are all the format cares about).
+func quote(v string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v) + `"`
+}
+
+func format(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
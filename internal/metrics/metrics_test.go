@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServeHTTP(t *testing.T) {
+	var none *Metrics
+	none.Sent(`a`) // doesn't need metrics to have been asked for
+
+	m := New()
+	for range 5 {
+		m.Sent(`a`)
+	}
+	m.Received(`a`, 3*time.Millisecond)
+	m.Received(`a`, 10*time.Millisecond) // right on a bucket's upper bound
+	m.Received(`a`, 20*time.Second)      // past the last one
+	m.Failed(`a`)
+	m.Lost(`a`)
+	m.Alert(`a`, 2)
+	m.Sent("b \"quoted\" \\ and\nsplit")
+
+	tests := []struct {
+		name        string
+		accept      string
+		contentType string
+		want        []string // lines the output must have
+		not         []string // lines it mustn't
+		eof         bool
+	}{
+		{
+			name:        `prometheus`,
+			contentType: `text/plain; version=0.0.4; charset=utf-8`,
+			want: []string{
+				`# HELP monet_probes_sent_total Probes sent.`,
+				`# TYPE monet_probes_sent_total counter`,
+				`# TYPE monet_probes_lost_total counter`,
+			},
+			not: []string{`# TYPE monet_probes_sent counter`},
+		},
+		{
+			name:        `openmetrics`,
+			accept:      `application/openmetrics-text;version=1.0.0,text/plain;version=0.0.4;q=0.5`,
+			contentType: `application/openmetrics-text; version=1.0.0; charset=utf-8`,
+			want: []string{
+				`# HELP monet_probes_sent Probes sent.`,
+				`# TYPE monet_probes_sent counter`,
+				`# TYPE monet_probes_lost counter`,
+			},
+			not: []string{`# TYPE monet_probes_sent_total counter`},
+			eof: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(`GET`, `/metrics`, nil)
+			if tt.accept != `` {
+				req.Header.Set(`Accept`, tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			m.ServeHTTP(rec, req)
+
+			if got := rec.Header().Get(`Content-Type`); got != tt.contentType {
+				t.Errorf(`content type %q, want %q`, got, tt.contentType)
+			}
+			body := rec.Body.String()
+			lines := strings.Split(strings.TrimSuffix(body, "\n"), "\n")
+			has := map[string]bool{}
+			for _, line := range lines {
+				has[line] = true
+			}
+			want := append([]string{
+				// the samples keep their _total either way
+				`monet_probes_sent_total{target="a"} 5`,
+				`monet_probes_received_total{target="a"} 3`,
+				`monet_probes_failed_total{target="a"} 1`,
+				`monet_probes_lost_total{target="a"} 1`,
+				`monet_loss_ratio{target="a"} 0.2`,
+				`monet_warning{target="a"} 1`,
+				`monet_alert_severity{target="a"} 2`,
+
+				// buckets count everything up to their bound (inclusive)
+				`# TYPE monet_rtt_seconds histogram`,
+				`monet_rtt_seconds_bucket{target="a",le="0.001"} 0`,
+				`monet_rtt_seconds_bucket{target="a",le="0.0025"} 0`,
+				`monet_rtt_seconds_bucket{target="a",le="0.005"} 1`,
+				`monet_rtt_seconds_bucket{target="a",le="0.01"} 2`,
+				`monet_rtt_seconds_bucket{target="a",le="0.025"} 2`,
+				`monet_rtt_seconds_bucket{target="a",le="10"} 2`,
+				`monet_rtt_seconds_bucket{target="a",le="+Inf"} 3`,
+				`monet_rtt_seconds_sum{target="a"} 20.013`,
+				`monet_rtt_seconds_count{target="a"} 3`,
+
+				// label values are escaped, and targets without anything to show still show up
+				`monet_probes_sent_total{target="b \"quoted\" \\ and\nsplit"} 1`,
+				`monet_probes_received_total{target="b \"quoted\" \\ and\nsplit"} 0`,
+				`monet_loss_ratio{target="b \"quoted\" \\ and\nsplit"} 0`,
+				`monet_rtt_seconds_bucket{target="b \"quoted\" \\ and\nsplit",le="+Inf"} 0`,
+			}, tt.want...)
+			for _, line := range want {
+				if !has[line] {
+					t.Errorf("missing %s in\n%s", line, body)
+				}
+			}
+			for _, line := range tt.not {
+				if has[line] {
+					t.Errorf(`unexpected %s`, line)
+				}
+			}
+			if eof := lines[len(lines)-1] == `# EOF`; eof != tt.eof {
+				t.Errorf(`ends with # EOF: %t, want %t`, eof, tt.eof)
+			}
+			if strings.Count(body, `# EOF`) > 1 {
+				t.Errorf(`more than one # EOF`)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package probe
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// base holds the plumbing shared by most probers: the event stream, the interval and shutdown.
+type base struct {
+	target string
+	id     int
+	events chan Event
+	ctx    context.Context // canceled once Stop is called
+	cancel context.CancelFunc
+	halted context.Context // canceled once Halt (or Stop) is called, ends the send loop
+	halt   context.CancelFunc
+	reset  chan struct{} // nudges the send loop when the interval changes
+	once   sync.Once
+	wg     sync.WaitGroup
+
+	mu       sync.Mutex
+	interval time.Duration
+}
+
+func newBase(target string) base {
+	ctx, cancel := context.WithCancel(context.Background())
+	halted, halt := context.WithCancel(ctx)
+	return base{
+		target:   target,
+		id:       rand.IntN(math.MaxUint16),
+		events:   make(chan Event, 20),
+		ctx:      ctx,
+		cancel:   cancel,
+		halted:   halted,
+		halt:     halt,
+		reset:    make(chan struct{}, 1),
+		interval: time.Second,
+	}
+}
+
+func (b *base) Target() string {
+	return b.target
+}
+
+func (b *base) Events() <-chan Event {
+	return b.events
+}
+
+func (b *base) Interval() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.interval
+}
+
+func (b *base) SetInterval(d time.Duration) {
+	b.mu.Lock()
+	b.interval = d
+	b.mu.Unlock()
+	select {
+	case b.reset <- struct{}{}:
+	default: // already nudged
+	}
+}
+
+func (b *base) Halt() {
+	b.halt()
+}
+
+func (b *base) Stop() {
+	b.once.Do(func() {
+		b.cancel()
+		b.wg.Wait()
+		close(b.events)
+	})
+}
+
+// emit sends an event to the listener, giving up if the prober has been stopped.
+func (b *base) emit(ev Event) bool {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	select {
+	case b.events <- ev:
+		return true
+	case <-b.ctx.Done():
+		return false
+	}
+}
+
+// launch tells the listener probe seq is going out and starts its clock, returning false once stopped.
+//
+// The event goes first so a reply can never beat it to the listener, and the clock starts after the listener
+// took it, so a listener slow to take it doesn't make the round trip look slower.
+func (b *base) launch(seq int) (time.Time, bool) {
+	if !b.emit(Event{Kind: Sent, ID: b.id, Seq: seq}) {
+		return time.Time{}, false
+	}
+	return time.Now(), true
+}
+
+// loop calls send every interval until the prober is halted (or stopped).
+// Sequence numbers keep counting up when the interval changes.
+//
+// Sends happen on the loop's goroutine, probers that block while waiting for a reply should use spawn.
+func (b *base) loop(send func(seq int)) {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		select {
+		case <-b.reset: // interval was set before starting
+		default:
+		}
+		ticker := time.NewTicker(b.Interval())
+		defer ticker.Stop()
+		for seq := 0; ; seq++ {
+			send(seq)
+			if !b.tick(ticker) {
+				return
+			}
+		}
+	}()
+}
+
+// tick waits for the next tick (picking up interval changes along the way), returning false once halted.
+func (b *base) tick(ticker *time.Ticker) bool {
+	for {
+		select {
+		case <-b.halted.Done():
+			return false
+		case <-b.reset:
+			ticker.Reset(b.Interval())
+		case <-ticker.C:
+			return b.halted.Err() == nil // both can be ready at once
+		}
+	}
+}
+
+// spawn runs fn in the background, making sure Stop waits for it to finish.
+func (b *base) spawn(fn func()) {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		fn()
+	}()
+}
@@ -0,0 +1,50 @@
+package probe
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHalt(t *testing.T) {
+	tests := []struct {
+		name string
+		rtt  time.Duration
+		recv int // replies after halting
+	}{
+		{`reply in flight`, 50 * time.Millisecond, 1},
+		{`lost in flight`, -1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewFake(`a`, func(int) time.Duration { return tt.rtt })
+			p.SetInterval(100 * time.Millisecond)
+			if err := p.Start(); err != nil {
+				t.Fatal(err)
+			}
+			defer p.Stop()
+			if ev := <-p.Events(); ev.Kind != Sent {
+				t.Fatalf(`first event: %s, want %s`, ev.Kind, Sent)
+			}
+			p.Halt()
+
+			sent, recv := 0, 0
+			timeout := time.After(400 * time.Millisecond) // a few intervals
+			for done := false; !done; {
+				select {
+				case ev := <-p.Events():
+					switch ev.Kind {
+					case Sent:
+						sent++
+					case Received:
+						recv++
+					}
+				case <-timeout:
+					done = true
+				}
+			}
+			if sent != 0 || recv != tt.recv {
+				t.Errorf(`after halting: %d sent, %d received, want 0 and %d`, sent, recv, tt.recv)
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package probe
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// DNS times real queries against a resolver, over UDP (falling back to TCP when the answer is truncated).
+//
+// Answers with a non-success rcode (SERVFAIL, NXDOMAIN, REFUSED, ...) are reported as failures.
+type DNS struct {
+	base
+	server  string // host:port of the resolver as given
+	dial    string // resolved ip:port (so looking up the resolver itself isn't part of every query)
+	name    dnsmessage.Name
+	qtype   dnsmessage.Type
+	timeout time.Duration
+	tcp     bool // skip UDP entirely
+}
+
+// dnsTypes maps the names people type (A, AAAA, MX, ...) to query types.
+var dnsTypes = map[string]dnsmessage.Type{}
+
+func init() {
+	for _, t := range []dnsmessage.Type{
+		dnsmessage.TypeA, dnsmessage.TypeNS, dnsmessage.TypeCNAME, dnsmessage.TypeSOA,
+		dnsmessage.TypePTR, dnsmessage.TypeMX, dnsmessage.TypeTXT, dnsmessage.TypeAAAA,
+		dnsmessage.TypeSRV,
+	} {
+		dnsTypes[strings.TrimPrefix(t.String(), `Type`)] = t
+	}
+}
+
+func newDNS(u *url.URL) (*DNS, error) {
+	q := u.Query()
+	timeout, err := durationParam(q, `timeout`, 2*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	fqdn := q.Get(`name`)
+	if fqdn == `` {
+		fqdn = `example.com`
+	}
+	if !strings.HasSuffix(fqdn, `.`) {
+		fqdn += `.`
+	}
+	name, err := dnsmessage.NewName(fqdn)
+	if err != nil {
+		return nil, fmt.Errorf(`name: %w`, err)
+	}
+
+	qtype := dnsmessage.TypeA
+	if v := q.Get(`type`); v != `` {
+		t, ok := dnsTypes[strings.ToUpper(v)]
+		if !ok {
+			return nil, fmt.Errorf(`type: unsupported query type %q`, v)
+		}
+		qtype = t
+	}
+
+	tcp := false
+	if v := q.Get(`tcp`); v != `` {
+		if tcp, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf(`tcp: %w`, err)
+		}
+	}
+
+	server := u.Host
+	if u.Port() == `` {
+		server = net.JoinHostPort(u.Hostname(), `53`)
+	}
+
+	// the label says what's asked, so it doesn't pass for a ping of the resolver (or another question asked of it)
+	label := url.Values{`name`: {strings.TrimSuffix(fqdn, `.`)}, `type`: {strings.TrimPrefix(qtype.String(), `Type`)}}
+	if tcp {
+		label.Set(`tcp`, `true`)
+	}
+
+	return &DNS{
+		base:    newBase((&url.URL{Scheme: `dns`, Host: u.Host, RawQuery: label.Encode()}).String()),
+		server:  server,
+		name:    name,
+		qtype:   qtype,
+		timeout: timeout,
+		tcp:     tcp,
+	}, nil
+}
+
+func (p *DNS) Start() error {
+	addr, err := net.ResolveUDPAddr(`udp`, p.server)
+	if err != nil {
+		return err
+	}
+	p.dial = addr.String()
+	p.loop(p.send)
+	return nil
+}
+
+func (p *DNS) send(seq int) {
+	sent, ok := p.launch(seq)
+	if !ok {
+		return
+	}
+	p.spawn(func() {
+		phases, err := p.resolve()
+		now := time.Now()
+		if err != nil {
+			if p.ctx.Err() == nil {
+				p.emit(Event{Kind: Failed, ID: p.id, Seq: seq, Time: now, Err: err})
+			}
+			return
+		}
+		p.emit(Event{Kind: Received, ID: p.id, Seq: seq, Time: now, Rtt: now.Sub(sent), TTL: -1, Phases: phases})
+	})
+}
+
+// errTruncated signals that the UDP answer didn't fit and the query should be retried over TCP.
+var errTruncated = errors.New(`truncated`)
+
+// resolve sends a single query, returning how long each transport took.
+func (p *DNS) resolve() ([]Phase, error) {
+	id := uint16(rand.Uint32())
+	msg, err := p.query(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var phases []Phase
+	if !p.tcp {
+		start := time.Now()
+		err = p.exchange(`udp`, id, msg)
+		phases = append(phases, Phase{`udp`, time.Since(start)})
+		if !errors.Is(err, errTruncated) {
+			return phases, err
+		}
+	}
+	start := time.Now()
+	err = p.exchange(`tcp`, id, msg)
+	phases = append(phases, Phase{`tcp`, time.Since(start)})
+	return phases, err
+}
+
+// ednsSize is the UDP answer size we advertise (what DNS flag day 2020 settled on), without EDNS0 it'd be 512 bytes.
+const ednsSize = 1232
+
+// query builds a question (advertising ednsSize with an EDNS0 OPT record) with room for the 2 byte length prefix used by TCP.
+func (p *DNS) query(id uint16) ([]byte, error) {
+	b := dnsmessage.NewBuilder(make([]byte, 2, 514), dnsmessage.Header{ID: id, RecursionDesired: true})
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(dnsmessage.Question{Name: p.name, Type: p.qtype, Class: dnsmessage.ClassINET}); err != nil {
+		return nil, err
+	}
+	if err := b.StartAdditionals(); err != nil {
+		return nil, err
+	}
+	var opt dnsmessage.ResourceHeader
+	if err := opt.SetEDNS0(ednsSize, dnsmessage.RCodeSuccess, false); err != nil {
+		return nil, err
+	}
+	if err := b.OPTResource(opt, dnsmessage.OPTResource{}); err != nil {
+		return nil, err
+	}
+	msg, err := b.Finish()
+	if err != nil {
+		return nil, err
+	}
+	binary.BigEndian.PutUint16(msg, uint16(len(msg)-2))
+	return msg, nil
+}
+
+// exchange sends the query over network ("udp" or "tcp") and checks the answer.
+func (p *DNS) exchange(network string, id uint16, msg []byte) error {
+	d := net.Dialer{Timeout: p.timeout}
+	conn, err := d.DialContext(p.ctx, network, p.dial)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(p.timeout)); err != nil {
+		return err
+	}
+
+	if network == `udp` {
+		if _, err := conn.Write(msg[2:]); err != nil {
+			return err
+		}
+		buf := make([]byte, ednsSize)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return err
+			}
+			if err := p.check(id, buf[:n]); !errors.Is(err, errMismatch) {
+				return err
+			}
+			// someone else's answer (or spoofed), keep waiting for ours
+		}
+	}
+
+	if _, err := conn.Write(msg); err != nil {
+		return err
+	}
+	var size [2]byte
+	if _, err := io.ReadFull(conn, size[:]); err != nil {
+		return err
+	}
+	buf := make([]byte, binary.BigEndian.Uint16(size[:]))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return err
+	}
+	return p.check(id, buf)
+}
+
+// errMismatch is returned by check when the answer isn't for our question.
+var errMismatch = errors.New(`answer doesn't match question`)
+
+// check makes sure the answer belongs to our question and was successful.
+func (p *DNS) check(id uint16, buf []byte) error {
+	var parser dnsmessage.Parser
+	h, err := parser.Start(buf)
+	if err != nil {
+		return errMismatch
+	}
+	if h.ID != id || !h.Response {
+		return errMismatch
+	}
+	q, err := parser.Question()
+	if err != nil || !strings.EqualFold(q.Name.String(), p.name.String()) || q.Type != p.qtype {
+		return errMismatch
+	}
+	if h.Truncated {
+		return errTruncated
+	}
+	if h.RCode != dnsmessage.RCodeSuccess {
+		return fmt.Errorf(`rcode: %s`, strings.TrimPrefix(h.RCode.String(), `RCode`))
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package probe
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// answer is what the test resolver says to a query, by name: ok. answers, missing. doesn't exist and
+// big. only fits over TCP (over UDP it's truncated)
+func answer(t *testing.T, query []byte, tcp bool) []byte {
+	var parser dnsmessage.Parser
+	h, err := parser.Start(query)
+	if err != nil {
+		t.Errorf(`resolver: %v`, err)
+		return nil
+	}
+	q, err := parser.Question()
+	if err != nil {
+		t.Errorf(`resolver: %v`, err)
+		return nil
+	}
+	if err := parser.SkipAllQuestions(); err != nil {
+		t.Errorf(`resolver: %v`, err)
+		return nil
+	}
+	if err := parser.SkipAllAnswers(); err != nil {
+		t.Errorf(`resolver: %v`, err)
+		return nil
+	}
+	if err := parser.SkipAllAuthorities(); err != nil {
+		t.Errorf(`resolver: %v`, err)
+		return nil
+	}
+	if opt, err := parser.AdditionalHeader(); err != nil || opt.Type != dnsmessage.TypeOPT || opt.Class != ednsSize {
+		t.Errorf(`resolver: query without an EDNS0 size (%v, %v)`, opt, err)
+	}
+	reply := dnsmessage.Header{ID: h.ID, Response: true}
+	switch q.Name.String() {
+	case `missing.`:
+		reply.RCode = dnsmessage.RCodeNameError
+	case `big.`:
+		reply.Truncated = !tcp
+	}
+	b := dnsmessage.NewBuilder(nil, reply)
+	if err := b.StartQuestions(); err != nil {
+		t.Errorf(`resolver: %v`, err)
+	}
+	if err := b.Question(q); err != nil {
+		t.Errorf(`resolver: %v`, err)
+	}
+	msg, err := b.Finish()
+	if err != nil {
+		t.Errorf(`resolver: %v`, err)
+	}
+	return msg
+}
+
+// resolver answers queries over UDP and TCP on the same (local) port, returning its address
+func resolver(t *testing.T) string {
+	t.Helper()
+	udp, err := net.ListenPacket(`udp`, `127.0.0.1:0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { udp.Close() })
+	tcp, err := net.Listen(`tcp`, udp.LocalAddr().String())
+	if err != nil {
+		t.Skipf(`tcp port to match udp: %v`, err)
+	}
+	t.Cleanup(func() { tcp.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, from, err := udp.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			udp.WriteTo(answer(t, buf[:n], false), from)
+		}
+	}()
+	go func() {
+		for {
+			conn, err := tcp.Accept()
+			if err != nil {
+				return
+			}
+			var size [2]byte
+			if _, err := io.ReadFull(conn, size[:]); err == nil {
+				query := make([]byte, binary.BigEndian.Uint16(size[:]))
+				if _, err := io.ReadFull(conn, query); err == nil {
+					msg := answer(t, query, true)
+					conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(msg))), msg...))
+				}
+			}
+			conn.Close()
+		}
+	}()
+	return udp.LocalAddr().String()
+}
+
+func TestDNS(t *testing.T) {
+	server := resolver(t)
+	tests := []struct {
+		name   string
+		query  string
+		want   Kind
+		phases string
+	}{
+		{`answered`, `name=ok`, Received, `udp`},
+		{`nxdomain`, `name=missing`, Failed, ``},
+		{`truncated`, `name=big`, Received, `udp tcp`},
+		{`tcp only`, `name=ok&tcp=true`, Received, `tcp`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := New(`dns://` + server + `?` + tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, ev := range collect(t, p, 3) {
+				if ev.Kind == Sent {
+					continue
+				}
+				if ev.Kind != tt.want {
+					t.Fatalf(`event %d: %s (%v), want %s`, i, ev.Kind, ev.Err, tt.want)
+				}
+				if ev.Kind == Failed && !strings.Contains(ev.Err.Error(), `NameError`) {
+					t.Errorf(`event %d: %v, want a NameError rcode`, i, ev.Err)
+				}
+				var phases []string
+				for _, ph := range ev.Phases {
+					phases = append(phases, ph.Name)
+				}
+				if got := strings.Join(phases, ` `); got != tt.phases {
+					t.Errorf(`event %d: phases %q, want %q`, i, got, tt.phases)
+				}
+			}
+		})
+	}
+}
+
+func TestDNSLabels(t *testing.T) {
+	tests := []struct {
+		targets []string
+		labels  []string
+	}{
+		{
+			[]string{`1.1.1.1`, `dns://1.1.1.1`},
+			[]string{`1.1.1.1`, `dns://1.1.1.1?name=example.com&type=A`},
+		},
+		{
+			[]string{`dns://1.1.1.1?name=example.org.`, `dns://1.1.1.1?name=example.org&type=aaaa&tcp=true`},
+			[]string{`dns://1.1.1.1?name=example.org&type=A`, `dns://1.1.1.1?name=example.org&tcp=true&type=AAAA`},
+		},
+		{
+			[]string{`dns://1.1.1.1:5353`, `dns://[2606:4700:4700::1111]?type=MX`},
+			[]string{`dns://1.1.1.1:5353?name=example.com&type=A`, `dns://[2606:4700:4700::1111]?name=example.com&type=MX`},
+		},
+	}
+	for _, tt := range tests {
+		for i, target := range tt.targets {
+			p, err := New(target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := p.Target(); got != tt.labels[i] {
+				t.Errorf(`%s: labeled %q, want %q`, target, got, tt.labels[i])
+			}
+		}
+	}
+}
+
+func TestDNSTargets(t *testing.T) {
+	tests := []struct {
+		target string
+		server string
+		ok     bool
+	}{
+		{`dns://127.0.0.1`, `127.0.0.1:53`, true},
+		{`dns://127.0.0.1:5353?name=example.org&type=aaaa&tcp=true&timeout=1s`, `127.0.0.1:5353`, true},
+		{`dns://127.0.0.1?type=BOGUS`, ``, false},
+		{`dns://127.0.0.1?tcp=maybe`, ``, false},
+		{`dns://127.0.0.1?timeout=soon`, ``, false},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := newDNS(u)
+		if (err == nil) != tt.ok {
+			t.Errorf(`%s: err = %v, want ok = %t`, tt.target, err, tt.ok)
+		}
+		if err == nil && p.server != tt.server {
+			t.Errorf(`%s: server %s, want %s`, tt.target, p.server, tt.server)
+		}
+	}
+}
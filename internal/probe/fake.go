@@ -0,0 +1,119 @@
+package probe
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Fake is a Prober that never touches the network.
+// Handy for developing the UI (and testing it) without raw sockets or an internet connection.
+type Fake struct {
+	base
+
+	// Rtt decides how long probe seq takes to come back; returning a negative value loses the probe.
+	Rtt func(seq int) time.Duration
+
+	// From is who answers (the target when empty), set it to a router's address to pretend the probes ran out of time to live.
+	From string
+
+	// Dup is the chance of a reply arriving twice (networks do that now and then), the copy a few milliseconds behind.
+	Dup float64
+}
+
+// NewFake creates a fake prober for target that replies according to rtt.
+func NewFake(target string, rtt func(seq int) time.Duration) *Fake {
+	return &Fake{base: newBase(target), Rtt: rtt}
+}
+
+// newFake parses `fake://name?rtt=20ms&jitter=5ms&loss=0.01&dup=0.01&hops=5&ttl=2` into a fake prober.
+//
+// The target is hops routers away, a ttl short of that has the probes answered by router number ttl (10.0.0.ttl)
+// after a proportional slice of the round trip time, like the time exceeded errors traceroute relies on.
+func newFake(u *url.URL) (*Fake, error) {
+	q := u.Query()
+	rtt, err := durationParam(q, `rtt`, 20*time.Millisecond)
+	if err != nil {
+		return nil, err
+	}
+	jitter, err := durationParam(q, `jitter`, 0)
+	if err != nil {
+		return nil, err
+	}
+	loss := 0.0
+	if v := q.Get(`loss`); v != `` {
+		if loss, err = strconv.ParseFloat(v, 64); err != nil {
+			return nil, err
+		}
+	}
+	dup := 0.0
+	if v := q.Get(`dup`); v != `` {
+		if dup, err = strconv.ParseFloat(v, 64); err != nil {
+			return nil, err
+		}
+	}
+	hops, ttl := 1, 0
+	if v := q.Get(`hops`); v != `` {
+		if hops, err = strconv.Atoi(v); err != nil || hops < 1 {
+			return nil, fmt.Errorf(`hops: must be a positive number, got %q`, v)
+		}
+	}
+	if v := q.Get(`ttl`); v != `` {
+		if ttl, err = strconv.Atoi(v); err != nil || ttl < 1 || ttl > 255 {
+			return nil, fmt.Errorf(`ttl: must be between 1 and 255, got %q`, v)
+		}
+	}
+	var from string
+	if ttl > 0 && ttl < hops {
+		from = fmt.Sprintf(`10.0.0.%d`, ttl)
+		rtt = rtt * time.Duration(ttl) / time.Duration(hops)
+		jitter = jitter * time.Duration(ttl) / time.Duration(hops)
+	}
+	f := NewFake(u.Host, func(int) time.Duration {
+		if rand.Float64() < loss {
+			return -1
+		}
+		if jitter > 0 {
+			return rtt + rand.N(jitter)
+		}
+		return rtt
+	})
+	f.From, f.Dup = from, dup
+	return f, nil
+}
+
+func (f *Fake) Start() error {
+	f.loop(func(seq int) {
+		if !f.emit(Event{Kind: Sent, ID: f.id, Seq: seq}) {
+			return
+		}
+		rtt := f.Rtt(seq)
+		if rtt < 0 {
+			return // lost in the mail
+		}
+		f.spawn(func() {
+			from := f.From
+			if from == `` {
+				from = f.target
+			}
+			select {
+			case <-time.After(rtt):
+				f.emit(Event{Kind: Received, ID: f.id, Seq: seq, Rtt: rtt, From: from})
+			case <-f.ctx.Done():
+				return
+			}
+			if rand.Float64() >= f.Dup {
+				return
+			}
+			late := time.Duration(1+rand.N(5)) * time.Millisecond
+			select {
+			case <-time.After(late):
+				f.emit(Event{Kind: Received, ID: f.id, Seq: seq, Rtt: rtt + late, From: from})
+			case <-f.ctx.Done():
+			}
+		})
+	})
+	return nil
+}
@@ -0,0 +1,38 @@
+package probe
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Hop finds the router ttl hops along the path to target by sending a few TTL-limited echo requests.
+// Reached is true when the target itself answered (the path is shorter than ttl).
+//
+// Routers answer with ICMP time exceeded errors, which requires raw sockets (and privileges).
+func Hop(target string, ttl int, timeout time.Duration) (addr string, reached bool, err error) {
+	p, err := newICMP(target, url.Values{`ttl`: {strconv.Itoa(ttl)}})
+	if err != nil {
+		return ``, false, err
+	}
+	p.SetInterval(timeout / 3)
+	if err := p.Start(); err != nil {
+		return ``, false, err
+	}
+	defer p.Stop()
+
+	deadline := time.After(timeout)
+	for {
+		select {
+		case <-deadline:
+			return ``, false, ErrNoReplies
+		case ev, ok := <-p.Events():
+			if !ok {
+				return ``, false, ErrNoReplies
+			}
+			if ev.Kind == Received {
+				return ev.From, ev.From == p.addr.IP.String(), nil
+			}
+		}
+	}
+}
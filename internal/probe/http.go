@@ -0,0 +1,197 @@
+package probe
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// HTTP times periodic requests, breaking each one down into phases (dns, connect, tls, ttfb).
+//
+// Every request uses a fresh connection (unless keepalive=true), so the breakdown reflects what a new client would see.
+// Responses other than 2xx and 3xx are reported as failures.
+type HTTP struct {
+	base
+	url    string
+	method string
+	client *http.Client
+}
+
+// NewHTTP creates an HTTP prober for target that sends its requests with client (handy for servers only it knows
+// how to trust, like httptest's), a nil client gets one of its own like any http(s):// target.
+func NewHTTP(target string, client *http.Client) (*HTTP, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	return newHTTP(u, client)
+}
+
+// newHTTP parses `https://host/path?timeout=10s&keepalive=false&method=HEAD&ca=ca.pem&insecure=false` into a prober.
+//
+// ca is a PEM file of certificates to trust instead of the system's (for servers behind a private CA),
+// insecure skips verifying certificates altogether. Both (like keepalive) only apply when client is nil.
+func newHTTP(u *url.URL, client *http.Client) (*HTTP, error) {
+	q := u.Query()
+	timeout, err := durationParam(q, `timeout`, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	keepalive := false
+	if v := q.Get(`keepalive`); v != `` {
+		if keepalive, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf(`keepalive: %w`, err)
+		}
+	}
+	method := http.MethodGet
+	if v := q.Get(`method`); v != `` {
+		method = v
+	}
+	config := &tls.Config{}
+	if v := q.Get(`insecure`); v != `` {
+		if config.InsecureSkipVerify, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf(`insecure: %w`, err)
+		}
+	}
+	if v := q.Get(`ca`); v != `` {
+		pem, err := os.ReadFile(v)
+		if err != nil {
+			return nil, fmt.Errorf(`ca: %w`, err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf(`ca: no certificates in %s`, v)
+		}
+	}
+
+	// monet's own parameters aren't sent to the server
+	for _, param := range []string{`timeout`, `keepalive`, `method`, `ca`, `insecure`} {
+		q.Del(param)
+	}
+	u.RawQuery = q.Encode()
+
+	if client == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.DisableKeepAlives = !keepalive
+		transport.TLSClientConfig = config
+		client = &http.Client{
+			Transport: transport,
+			Timeout:   timeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse // time a single round trip, not a chain of them
+			},
+		}
+	}
+
+	return &HTTP{
+		base:   newBase(u.String()),
+		url:    u.String(),
+		method: method,
+		client: client,
+	}, nil
+}
+
+func (p *HTTP) Start() error {
+	if _, err := http.NewRequest(p.method, p.url, nil); err != nil {
+		return err
+	}
+	p.loop(p.send)
+	return nil
+}
+
+func (p *HTTP) Stop() {
+	p.base.Stop()
+	p.client.CloseIdleConnections()
+}
+
+func (p *HTTP) send(seq int) {
+	sent, ok := p.launch(seq)
+	if !ok {
+		return
+	}
+	p.spawn(func() {
+		var lookup, dial, handshake, first timer
+		trace := &httptrace.ClientTrace{
+			DNSStart:             func(httptrace.DNSStartInfo) { lookup.start() },
+			DNSDone:              func(httptrace.DNSDoneInfo) { lookup.stop() },
+			ConnectStart:         func(string, string) { dial.start() },
+			ConnectDone:          func(string, string, error) { dial.stop() },
+			TLSHandshakeStart:    func() { handshake.start() },
+			TLSHandshakeDone:     func(tls.ConnectionState, error) { handshake.stop() },
+			GotFirstResponseByte: func() { first.stop() },
+		}
+
+		req, err := http.NewRequestWithContext(httptrace.WithClientTrace(p.ctx, trace), p.method, p.url, nil)
+		if err != nil {
+			p.emit(Event{Kind: Failed, ID: p.id, Seq: seq, Err: err})
+			return
+		}
+		first.began = sent
+		resp, err := p.client.Do(req)
+		if err != nil {
+			if p.ctx.Err() == nil {
+				p.emit(Event{Kind: Failed, ID: p.id, Seq: seq, Err: err})
+			}
+			return
+		}
+		_, err = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+		resp.Body.Close()
+		now := time.Now()
+		if err != nil {
+			p.emit(Event{Kind: Failed, ID: p.id, Seq: seq, Time: now, Err: err})
+			return
+		}
+		if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+			p.emit(Event{Kind: Failed, ID: p.id, Seq: seq, Time: now, Err: fmt.Errorf(`status: %s`, resp.Status)})
+			return
+		}
+		p.emit(Event{
+			Kind: Received,
+			ID:   p.id,
+			Seq:  seq,
+			Time: now,
+			Rtt:  now.Sub(sent),
+			TTL:  -1,
+			Phases: []Phase{
+				{`dns`, lookup.elapsed()},
+				{`connect`, dial.elapsed()},
+				{`tls`, handshake.elapsed()},
+				{`ttfb`, first.elapsed()},
+			},
+		})
+	})
+}
+
+// timer measures a single phase of a request (the zero value is ready to use).
+// Trace hooks can fire from different goroutines (happy eyeballs dials several addresses at once), hence the lock.
+type timer struct {
+	mu    sync.Mutex
+	began time.Time
+	took  time.Duration
+}
+
+func (t *timer) start() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.began = time.Now()
+}
+
+func (t *timer) stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.took = time.Since(t.began)
+}
+
+func (t *timer) elapsed() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.took
+}
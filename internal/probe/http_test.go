@@ -0,0 +1,97 @@
+package probe
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHTTP(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc(`/ok`, func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc(`/moved`, func(w http.ResponseWriter, r *http.Request) { http.Redirect(w, r, `/ok`, http.StatusFound) })
+	mux.HandleFunc(`/missing`, http.NotFound)
+	mux.HandleFunc(`/broken`, func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusInternalServerError) })
+	plain := httptest.NewServer(mux)
+	defer plain.Close()
+	secure := httptest.NewTLSServer(mux)
+	defer secure.Close()
+
+	// the private CA behind secure, for ca=
+	ca := filepath.Join(t.TempDir(), `ca.pem`)
+	if err := os.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: `CERTIFICATE`, Bytes: secure.Certificate().Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		client *http.Client
+		want   Kind
+		phases []string // that took some time
+	}{
+		{`ok`, plain.URL + `/ok`, nil, Received, []string{`connect`, `ttfb`}},
+		{`redirect`, plain.URL + `/moved`, nil, Received, nil},
+		{`not found`, plain.URL + `/missing`, nil, Failed, nil},
+		{`server error`, plain.URL + `/broken`, nil, Failed, nil},
+		{`keepalive`, plain.URL + `/ok?keepalive=true`, nil, Received, nil},
+		{`untrusted`, secure.URL + `/ok`, nil, Failed, nil},
+		{`insecure`, secure.URL + `/ok?insecure=true`, nil, Received, []string{`tls`}},
+		{`private ca`, secure.URL + `/ok?ca=` + ca, nil, Received, []string{`connect`, `tls`, `ttfb`}},
+		{`client`, secure.URL + `/ok`, secure.Client(), Received, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewHTTP(tt.target, tt.client)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, ev := range collect(t, p, 3) {
+				if ev.Kind == Sent {
+					continue
+				}
+				if ev.Kind != tt.want {
+					t.Fatalf(`event %d: %s (%v), want %s`, i, ev.Kind, ev.Err, tt.want)
+				}
+				if ev.Kind != Received {
+					continue
+				}
+				if ev.Rtt <= 0 || ev.Rtt > time.Second {
+					t.Errorf(`event %d: rtt %s`, i, ev.Rtt)
+				}
+				took := map[string]time.Duration{}
+				for _, ph := range ev.Phases {
+					took[ph.Name] = ph.Dur
+				}
+				for _, name := range tt.phases {
+					if took[name] <= 0 || took[name] > ev.Rtt {
+						t.Errorf(`event %d: %s took %s of %s`, i, name, took[name], ev.Rtt)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestHTTPTargets(t *testing.T) {
+	tests := []struct {
+		target string
+		ok     bool
+	}{
+		{`https://example.com/health`, true},
+		{`https://example.com/health?timeout=1s&keepalive=true&method=HEAD&insecure=false`, true},
+		{`https://example.com/health?timeout=soon`, false},
+		{`https://example.com/health?keepalive=maybe`, false},
+		{`https://example.com/health?insecure=maybe`, false},
+		{`https://example.com/health?ca=/does/not/exist.pem`, false},
+	}
+	for _, tt := range tests {
+		if _, err := NewHTTP(tt.target, nil); (err == nil) != tt.ok {
+			t.Errorf(`%s: err = %v, want ok = %t`, tt.target, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package probe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/url"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// ICMP is a native ICMP/ICMPv6 echo prober.
+//
+// A single socket is kept open for the lifetime of the prober, so changing the interval doesn't reset sequence numbers.
+// Both privileged (raw) and unprivileged (datagram) sockets are supported, the latter being tried first unless told otherwise.
+type ICMP struct {
+	base
+
+	privileged *bool // nil = try unprivileged, then privileged
+	ttl        int   // time to live (or hop limit) of requests, 0 = system default
+
+	addr  *net.IPAddr
+	v6    bool
+	raw   bool
+	conn  net.PacketConn
+	token uint64 // included in every payload to make sure replies are really ours
+
+	inflight sync.Map // uint16 (wire sequence) -> echo
+}
+
+// echo is what we remember about a request until it is replaced by the next request with the same wire sequence.
+type echo struct {
+	seq  int       // sequence as reported in events (doesn't wrap at 16 bits)
+	sent time.Time // when the request was written to the socket
+}
+
+// payloadSize mirrors the 56 bytes of data sent by the ping CLI.
+const payloadSize = 56
+
+func newICMP(target string, q url.Values) (*ICMP, error) {
+	p := &ICMP{base: newBase(target), token: rand.Uint64()}
+	if v := q.Get(`privileged`); v != `` {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf(`privileged: %w`, err)
+		}
+		p.privileged = &b
+	}
+	if v := q.Get(`ttl`); v != `` {
+		ttl, err := strconv.Atoi(v)
+		if err != nil || ttl < 1 || ttl > 255 {
+			return nil, fmt.Errorf(`ttl: must be between 1 and 255, got %q`, v)
+		}
+		p.ttl = ttl
+		if p.privileged == nil {
+			// routers answer with time exceeded errors, which datagram sockets don't hand to us
+			privileged := true
+			p.privileged = &privileged
+		}
+	}
+	return p, nil
+}
+
+func (p *ICMP) Start() error {
+	addr, err := net.ResolveIPAddr(`ip`, p.target)
+	if err != nil {
+		return err
+	}
+	p.addr = addr
+	p.v6 = addr.IP.To4() == nil
+
+	if err := p.listen(); err != nil {
+		return err
+	}
+
+	// ask the kernel for the TTL/hop limit and (where available) receive timestamps of every reply
+	if p.v6 {
+		_ = ipv6.NewPacketConn(p.conn).SetControlMessage(ipv6.FlagHopLimit, true)
+	} else if !p.raw {
+		_ = ipv4.NewPacketConn(p.conn).SetControlMessage(ipv4.FlagTTL, true)
+	}
+	if sc, ok := p.conn.(syscallConn); ok {
+		_ = enableTimestamps(sc)
+	}
+	if p.ttl > 0 {
+		if err := p.setTTL(p.ttl); err != nil {
+			p.conn.Close()
+			return err
+		}
+	}
+
+	p.wg.Add(1)
+	go p.read()
+	p.loop(p.send)
+	return nil
+}
+
+func (p *ICMP) Stop() {
+	p.cancel()
+	if p.conn != nil {
+		p.conn.Close() // unblock the read loop
+	}
+	p.base.Stop()
+}
+
+// listen opens the socket, preferring unprivileged datagram sockets over raw ones.
+func (p *ICMP) listen() (err error) {
+	if p.privileged == nil || !*p.privileged {
+		p.conn, err = listenDatagram(p.v6)
+		if err == nil || p.privileged != nil {
+			return err
+		}
+	}
+	network, address := `ip4:icmp`, `0.0.0.0`
+	if p.v6 {
+		network, address = `ip6:ipv6-icmp`, `::`
+	}
+	conn, rawErr := net.ListenPacket(network, address)
+	if rawErr != nil {
+		if err != nil {
+			return fmt.Errorf(`unprivileged: %v; privileged: %w`, err, rawErr)
+		}
+		return rawErr
+	}
+	p.conn, p.raw = conn, true
+	return nil
+}
+
+// setTTL changes the time to live (or hop limit) of future requests.
+func (p *ICMP) setTTL(ttl int) error {
+	if p.v6 {
+		return ipv6.NewPacketConn(p.conn).SetHopLimit(ttl)
+	}
+	return ipv4.NewPacketConn(p.conn).SetTTL(ttl)
+}
+
+func (p *ICMP) send(seq int) {
+	var typ icmp.Type = ipv4.ICMPTypeEcho
+	if p.v6 {
+		typ = ipv6.ICMPTypeEchoRequest
+	}
+
+	var dst net.Addr = p.addr
+	if !p.raw {
+		dst = &net.UDPAddr{IP: p.addr.IP, Zone: p.addr.Zone}
+	}
+
+	sent, ok := p.launch(seq)
+	if !ok {
+		return
+	}
+	msg := icmp.Message{
+		Type: typ,
+		Body: &icmp.Echo{ID: p.id, Seq: int(uint16(seq)), Data: p.payload(sent)},
+	}
+	b, err := msg.Marshal(nil) // kernel computes the ICMPv6 checksum
+	if err != nil {
+		p.emit(Event{Kind: Failed, ID: p.id, Seq: seq, Err: err})
+		return
+	}
+	p.inflight.Store(uint16(seq), echo{seq: seq, sent: sent})
+	if _, err := p.conn.WriteTo(b, dst); err != nil {
+		p.emit(Event{Kind: Failed, ID: p.id, Seq: seq, Err: fmt.Errorf(`on-send-err: %w`, err)})
+	}
+}
+
+func (p *ICMP) read() {
+	defer p.wg.Done()
+	buf := make([]byte, 1500)
+	oob := make([]byte, 256)
+	for {
+		n, oobn, from, err := readMsg(p.conn, buf, oob)
+		now := time.Now()
+		if err != nil {
+			if p.ctx.Err() != nil {
+				return // closed by Stop
+			}
+			p.emit(Event{Kind: Failed, ID: p.id, Seq: -1, Err: fmt.Errorf(`on-recv-err: %w`, err)})
+			continue
+		}
+		if stamp, ok := kernelTime(oob[:oobn]); ok {
+			now = stamp
+		}
+		p.handle(buf[:n], oob[:oobn], from, now)
+	}
+}
+
+// handle parses a single packet from the socket, emitting an event if it is one of our replies
+// (or a router telling us one of our requests ran out of time to live).
+func (p *ICMP) handle(b, oob []byte, from string, now time.Time) {
+	ttl := -1
+	proto := 1 // ICMP
+	if p.v6 {
+		proto = 58 // ICMPv6
+		var cm ipv6.ControlMessage
+		if cm.Parse(oob) == nil && cm.HopLimit > 0 { // 0 when the kernel didn't say
+			ttl = cm.HopLimit
+		}
+	} else if p.raw {
+		// raw IPv4 sockets include the IP header
+		h, err := ipv4.ParseHeader(b)
+		if err != nil {
+			return
+		}
+		ttl = h.TTL
+		b = b[h.Len:]
+	} else {
+		var cm ipv4.ControlMessage
+		if cm.Parse(oob) == nil && cm.TTL > 0 {
+			ttl = cm.TTL
+		}
+	}
+
+	msg, err := icmp.ParseMessage(proto, b)
+	if err != nil {
+		return
+	}
+	switch body := msg.Body.(type) {
+	case *icmp.Echo:
+		if msg.Type != ipv4.ICMPTypeEchoReply && msg.Type != ipv6.ICMPTypeEchoReply {
+			return // not a reply (raw sockets see our own requests on loopback)
+		}
+		if p.raw && body.ID != p.id {
+			return // someone else's ping (datagram sockets are filtered by the kernel)
+		}
+		req, ok := p.request(body.Seq)
+		if !ok {
+			return
+		}
+		if err := p.verify(body.Data, req); err != nil {
+			p.emit(Event{Kind: Failed, ID: p.id, Seq: req.seq, Time: now, From: from, Err: err})
+			return
+		}
+		p.emit(Event{Kind: Received, ID: p.id, Seq: req.seq, Time: now, Rtt: now.Sub(req.sent), TTL: ttl, From: from})
+
+	case *icmp.TimeExceeded:
+		req, ok := p.quoted(body.Data)
+		if !ok {
+			return
+		}
+		p.emit(Event{Kind: Received, ID: p.id, Seq: req.seq, Time: now, Rtt: now.Sub(req.sent), TTL: ttl, From: from})
+
+	case *icmp.DstUnreach:
+		req, ok := p.quoted(body.Data)
+		if !ok {
+			return
+		}
+		err := fmt.Errorf(`destination unreachable (code %d) from %s`, msg.Code, from)
+		p.emit(Event{Kind: Failed, ID: p.id, Seq: req.seq, Time: now, From: from, Err: err})
+	}
+}
+
+// request looks up the request with the given wire sequence.
+func (p *ICMP) request(seq int) (echo, bool) {
+	v, ok := p.inflight.Load(uint16(seq))
+	if !ok {
+		return echo{}, false
+	}
+	return v.(echo), true
+}
+
+// quoted finds our request in the original datagram quoted by an ICMP error (IP header + start of our request).
+func (p *ICMP) quoted(data []byte) (echo, bool) {
+	if p.v6 {
+		const ipv6HeaderLen = 40 // extension headers would be news to us, they aren't something ping sends
+		if len(data) < ipv6HeaderLen {
+			return echo{}, false
+		}
+		data = data[ipv6HeaderLen:]
+	} else {
+		h, err := ipv4.ParseHeader(data)
+		if err != nil || len(data) < h.Len {
+			return echo{}, false
+		}
+		data = data[h.Len:]
+	}
+	if len(data) < 8 {
+		return echo{}, false
+	}
+	// type (1), code (1), checksum (2), id (2), seq (2)
+	id := int(binary.BigEndian.Uint16(data[4:]))
+	seq := int(binary.BigEndian.Uint16(data[6:]))
+	if p.raw && id != p.id {
+		return echo{}, false
+	}
+	return p.request(seq)
+}
+
+// errPayload is returned when a reply doesn't carry the payload we sent.
+var errPayload = errors.New(`payload mismatch`)
+
+// payload is the data carried by a request: send time, our token and the same filler as the ping CLI.
+func (p *ICMP) payload(sent time.Time) []byte {
+	data := make([]byte, payloadSize)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	binary.BigEndian.PutUint64(data, uint64(sent.UnixNano()))
+	binary.BigEndian.PutUint64(data[8:], p.token)
+	return data
+}
+
+// verify ensures the echoed payload is byte-for-byte what we sent.
+func (p *ICMP) verify(data []byte, req echo) error {
+	if !bytes.Equal(data, p.payload(req.sent)) {
+		return errPayload
+	}
+	return nil
+}
+
+type syscallConn interface {
+	SyscallConn() (syscall.RawConn, error)
+}
+
+// readMsg reads a packet (who it's from) and its control messages from either a raw or datagram socket.
+func readMsg(conn net.PacketConn, b, oob []byte) (n, oobn int, from string, err error) {
+	switch c := conn.(type) {
+	case *net.IPConn:
+		var addr *net.IPAddr
+		n, oobn, _, addr, err = c.ReadMsgIP(b, oob)
+		if addr != nil {
+			from = addr.IP.String()
+		}
+	case *net.UDPConn:
+		var addr *net.UDPAddr
+		n, oobn, _, addr, err = c.ReadMsgUDP(b, oob)
+		if addr != nil {
+			from = addr.IP.String()
+		}
+	default:
+		var addr net.Addr
+		n, addr, err = c.ReadFrom(b)
+		if addr != nil {
+			from = addr.String()
+		}
+	}
+	return n, oobn, from, err
+}
@@ -0,0 +1,13 @@
+//go:build !(linux || darwin)
+
+package probe
+
+import (
+	"errors"
+	"net"
+)
+
+// listenDatagram isn't supported here, raw sockets (privileged=true) are the only option.
+func listenDatagram(bool) (net.PacketConn, error) {
+	return nil, errors.New(`unprivileged icmp sockets are not supported on this platform`)
+}
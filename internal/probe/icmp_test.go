@@ -0,0 +1,186 @@
+package probe
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// testICMP is a prober that never opened a socket, with request seq (70000 on the wire is 4464) sent at sent
+func testICMP(v6, raw bool, sent time.Time) *ICMP {
+	p := &ICMP{base: newBase(`x`), v6: v6, raw: raw, token: 42}
+	p.inflight.Store(uint16(70000%(1<<16)), echo{seq: 70000, sent: sent})
+	return p
+}
+
+// request is an echo request as it went out on the wire
+func request(t *testing.T, v6 bool, id, seq int, data []byte) []byte {
+	t.Helper()
+	var typ icmp.Type = ipv4.ICMPTypeEcho
+	if v6 {
+		typ = ipv6.ICMPTypeEchoRequest
+	}
+	b, err := (&icmp.Message{Type: typ, Body: &icmp.Echo{ID: id, Seq: seq, Data: data}}).Marshal(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// quote is what ICMP errors carry of the request that caused them: its IP header and (at least) 8 bytes of it
+func quote(t *testing.T, v6 bool, req []byte) []byte {
+	t.Helper()
+	if v6 {
+		return append(make([]byte, 40), req[:8]...)
+	}
+	h := ipv4.Header{Version: ipv4.Version, Len: ipv4.HeaderLen, TotalLen: ipv4.HeaderLen + len(req), TTL: 1, Protocol: 1,
+		Src: net.IPv4(192, 0, 2, 1), Dst: net.IPv4(198, 51, 100, 1)}
+	b, err := h.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append(b, req[:8]...)
+}
+
+// reply marshals an ICMP message, behind an IP header with ttl for raw sockets
+func reply(t *testing.T, typ icmp.Type, body icmp.MessageBody, raw bool, ttl int) []byte {
+	t.Helper()
+	b, err := (&icmp.Message{Type: typ, Body: body}).Marshal(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !raw {
+		return b
+	}
+	h := ipv4.Header{Version: ipv4.Version, Len: ipv4.HeaderLen, TotalLen: ipv4.HeaderLen + len(b), TTL: ttl, Protocol: 1,
+		Src: net.IPv4(10, 0, 0, 1), Dst: net.IPv4(192, 0, 2, 1)}
+	hb, err := h.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append(hb, b...)
+}
+
+func TestICMPHandle(t *testing.T) {
+	sent := time.Now()
+	now := sent.Add(12 * time.Millisecond)
+	good := testICMP(false, false, sent).payload(sent) // the token is the same for them all
+	bad := append([]byte{}, good...)
+	bad[len(bad)-1]++
+
+	tests := []struct {
+		name    string
+		v6, raw bool
+		reply   func(id int) []byte
+		want    *Event // nil when the reply should be ignored
+		err     error
+	}{
+		{
+			name: `echo reply`,
+			reply: func(id int) []byte {
+				return reply(t, ipv4.ICMPTypeEchoReply, &icmp.Echo{ID: id, Seq: 4464, Data: good}, false, 0)
+			},
+			want: &Event{Kind: Received, Seq: 70000, Rtt: 12 * time.Millisecond, TTL: -1},
+		},
+		{
+			name: `echo reply with someone else's payload`,
+			reply: func(id int) []byte {
+				return reply(t, ipv4.ICMPTypeEchoReply, &icmp.Echo{ID: id, Seq: 4464, Data: bad}, false, 0)
+			},
+			want: &Event{Kind: Failed, Seq: 70000},
+			err:  errPayload,
+		},
+		{
+			name: `echo reply to nothing`,
+			reply: func(id int) []byte {
+				return reply(t, ipv4.ICMPTypeEchoReply, &icmp.Echo{ID: id, Seq: 1, Data: good}, false, 0)
+			},
+		},
+		{
+			name: `our own request`,
+			reply: func(id int) []byte {
+				return reply(t, ipv4.ICMPTypeEcho, &icmp.Echo{ID: id, Seq: 4464, Data: good}, false, 0)
+			},
+		},
+		{
+			name: `time exceeded`,
+			reply: func(id int) []byte {
+				return reply(t, ipv4.ICMPTypeTimeExceeded, &icmp.TimeExceeded{Data: quote(t, false, request(t, false, id, 4464, good))}, false, 0)
+			},
+			want: &Event{Kind: Received, Seq: 70000, Rtt: 12 * time.Millisecond, TTL: -1},
+		},
+		{
+			name: `time exceeded for nothing`,
+			reply: func(id int) []byte {
+				return reply(t, ipv4.ICMPTypeTimeExceeded, &icmp.TimeExceeded{Data: quote(t, false, request(t, false, id, 1, good))}, false, 0)
+			},
+		},
+		{
+			name: `time exceeded quoting too little`,
+			reply: func(id int) []byte {
+				return reply(t, ipv4.ICMPTypeTimeExceeded, &icmp.TimeExceeded{Data: quote(t, false, request(t, false, id, 4464, good))[:24]}, false, 0)
+			},
+		},
+		{
+			name: `raw time exceeded`,
+			raw:  true,
+			reply: func(id int) []byte {
+				return reply(t, ipv4.ICMPTypeTimeExceeded, &icmp.TimeExceeded{Data: quote(t, false, request(t, false, id, 4464, good))}, true, 250)
+			},
+			want: &Event{Kind: Received, Seq: 70000, Rtt: 12 * time.Millisecond, TTL: 250},
+		},
+		{
+			name: `raw time exceeded for someone else's ping`,
+			raw:  true,
+			reply: func(id int) []byte {
+				return reply(t, ipv4.ICMPTypeTimeExceeded, &icmp.TimeExceeded{Data: quote(t, false, request(t, false, id+1, 4464, good))}, true, 250)
+			},
+		},
+		{
+			name: `ipv6 time exceeded`,
+			v6:   true,
+			reply: func(id int) []byte {
+				return reply(t, ipv6.ICMPTypeTimeExceeded, &icmp.TimeExceeded{Data: quote(t, true, request(t, true, id, 4464, good))}, false, 0)
+			},
+			want: &Event{Kind: Received, Seq: 70000, Rtt: 12 * time.Millisecond, TTL: -1},
+		},
+		{
+			name: `unreachable`,
+			reply: func(id int) []byte {
+				return reply(t, ipv4.ICMPTypeDestinationUnreachable, &icmp.DstUnreach{Data: quote(t, false, request(t, false, id, 4464, good))}, false, 0)
+			},
+			want: &Event{Kind: Failed, Seq: 70000},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := testICMP(tt.v6, tt.raw, sent)
+			p.handle(tt.reply(p.id), nil, `10.0.0.1`, now)
+			select {
+			case ev := <-p.events:
+				if tt.want == nil {
+					t.Fatalf(`got %s for seq %d, want nothing`, ev.Kind, ev.Seq)
+				}
+				if ev.Kind != tt.want.Kind || ev.Seq != tt.want.Seq || ev.Rtt != tt.want.Rtt || ev.TTL != tt.want.TTL {
+					t.Errorf(`got %s seq %d rtt %s ttl %d, want %s seq %d rtt %s ttl %d`,
+						ev.Kind, ev.Seq, ev.Rtt, ev.TTL, tt.want.Kind, tt.want.Seq, tt.want.Rtt, tt.want.TTL)
+				}
+				if ev.ID != p.id || ev.From != `10.0.0.1` || !ev.Time.Equal(now) {
+					t.Errorf(`got id %d from %q at %s, want %d from 10.0.0.1 at %s`, ev.ID, ev.From, ev.Time, p.id, now)
+				}
+				if tt.err != nil && !errors.Is(ev.Err, tt.err) {
+					t.Errorf(`got error %v, want %v`, ev.Err, tt.err)
+				}
+			default:
+				if tt.want != nil {
+					t.Fatalf(`got nothing, want %s`, tt.want.Kind)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+//go:build linux || darwin
+
+package probe
+
+import (
+	"net"
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// listenDatagram opens an unprivileged ICMP socket (see net.ipv4.ping_group_range on linux).
+func listenDatagram(v6 bool) (net.PacketConn, error) {
+	family, proto := syscall.AF_INET, syscall.IPPROTO_ICMP
+	var sa syscall.Sockaddr = &syscall.SockaddrInet4{}
+	if v6 {
+		family, proto = syscall.AF_INET6, syscall.IPPROTO_ICMPV6
+		sa = &syscall.SockaddrInet6{}
+	}
+	s, err := syscall.Socket(family, syscall.SOCK_DGRAM, proto)
+	if err != nil {
+		return nil, os.NewSyscallError(`socket`, err)
+	}
+	if runtime.GOOS == `darwin` && !v6 {
+		// darwin includes the IP header unless told otherwise
+		const sysIP_STRIPHDR = 0x17
+		if err := syscall.SetsockoptInt(s, syscall.IPPROTO_IP, sysIP_STRIPHDR, 1); err != nil {
+			syscall.Close(s)
+			return nil, os.NewSyscallError(`setsockopt`, err)
+		}
+	}
+	if err := syscall.Bind(s, sa); err != nil {
+		syscall.Close(s)
+		return nil, os.NewSyscallError(`bind`, err)
+	}
+	f := os.NewFile(uintptr(s), `datagram-oriented icmp`)
+	defer f.Close()
+	return net.FilePacketConn(f)
+}
@@ -0,0 +1,119 @@
+// Package probe decouples monet from the things doing the actual probing.
+//
+// Every kind of probe (ICMP, TCP, HTTP, a fake for development, ...) implements Prober,
+// so the TUI, the chooser and anything else can consume the same stream of events.
+package probe
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Kind describes what happened to a probe.
+type Kind uint8
+
+const (
+	Sent     Kind = iota // a probe left the building
+	Received             // a probe came back (Rtt is populated)
+	Failed               // something went wrong (Err is populated)
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Sent:
+		return `sent`
+	case Received:
+		return `received`
+	case Failed:
+		return `failed`
+	}
+	return fmt.Sprintf(`kind(%d)`, k)
+}
+
+// Event is a single thing that happened to a probe.
+//
+// ID + Seq uniquely identify a probe for the lifetime of a Prober (even across SetInterval calls).
+type Event struct {
+	Kind Kind
+	ID   int
+	Seq  int           // -1 when a failure can't be tied to a specific probe
+	Time time.Time     // when the event happened
+	Rtt  time.Duration // round trip time (Received only)
+	TTL  int           // time to live (or hop limit) of the reply, -1 when unknown (Received only)
+	From string        // who answered, when known (a router on the path if the probe ran out of time to live)
+	Err  error         // what went wrong (Failed only)
+
+	Phases []Phase // breakdown of Rtt for probers that have one (Received only)
+}
+
+// Phase is a named part of a round trip (dns, connect, tls, ...).
+type Phase struct {
+	Name string
+	Dur  time.Duration
+}
+
+// Prober sends probes at an interval and reports what happens to them.
+type Prober interface {
+	Start() error              // start probing in the background
+	Halt()                     // stop sending probes (replies to the ones already sent keep coming until Stop)
+	Stop()                     // stop probing and close the Events channel
+	SetInterval(time.Duration) // change the time between probes (safe to call while running)
+	Interval() time.Duration   // current time between probes
+	Events() <-chan Event      // stream of sent, received and failed probes
+	Target() string            // human readable target (used for legends)
+}
+
+// Reporter is implemented by probers that know more than round trip times (like which direction packets were lost in).
+type Reporter interface {
+	Report() string
+}
+
+// New creates a Prober given a target.
+//
+// Bare hosts (`1.1.1.1`, `example.com`) are pinged, otherwise the scheme picks the prober:
+//
+//	icmp://1.1.1.1?privileged=true&ttl=3
+//	tcp://example.com:443?timeout=5s
+//	https://example.com/health?timeout=10s&keepalive=false&method=HEAD&ca=ca.pem&insecure=false
+//	dns://1.1.1.1?name=example.com&type=AAAA&timeout=2s&tcp=false
+//	udp://example.com:9798 (talking to `monet reflect`)
+//	fake://anything?rtt=20ms&jitter=5ms&loss=0.01&dup=0.01&hops=5&ttl=2
+func New(target string) (Prober, error) {
+	if !strings.Contains(target, `://`) {
+		return newICMP(target, nil)
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case `icmp`:
+		return newICMP(u.Hostname(), u.Query())
+	case `tcp`:
+		return newTCP(u)
+	case `http`, `https`:
+		return newHTTP(u, nil)
+	case `dns`:
+		return newDNS(u)
+	case `udp`:
+		return newUDP(u), nil
+	case `fake`:
+		return newFake(u)
+	}
+	return nil, fmt.Errorf(`unsupported target scheme: %q`, u.Scheme)
+}
+
+// durationParam parses an optional duration from the query string of a target.
+func durationParam(q url.Values, name string, fallback time.Duration) (time.Duration, error) {
+	v := q.Get(name)
+	if v == `` {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf(`%s: %w`, name, err)
+	}
+	return d, nil
+}
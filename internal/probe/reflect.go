@@ -0,0 +1,59 @@
+package probe
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+// Reflect echoes UDP probes back to their sender (the server side of `udp://` targets), until conn is closed.
+//
+// Each datagram is stamped with when it arrived and how many datagrams the sender's session has delivered so far.
+func Reflect(conn net.PacketConn) error {
+	type session struct {
+		count uint64
+		seen  time.Time
+	}
+	sessions := make(map[uint64]*session)
+	lastPrune := time.Now()
+
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		now := time.Now()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		r, ok := unmarshalReflection(buf[:n])
+		if !ok || !r.reflected.IsZero() {
+			continue // not a probe (or a reflection of a reflection)
+		}
+
+		s := sessions[r.token]
+		if s == nil {
+			s = &session{}
+			sessions[r.token] = s
+		}
+		s.count++
+		s.seen = now
+
+		r.reflected = now
+		r.count = s.count
+		if _, err := conn.WriteTo(r.marshal(), addr); err != nil && errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+
+		// forget about clients that went away
+		if now.Sub(lastPrune) > time.Minute {
+			lastPrune = now
+			for token, s := range sessions {
+				if now.Sub(s.seen) > 5*time.Minute {
+					delete(sessions, token)
+				}
+			}
+		}
+	}
+}
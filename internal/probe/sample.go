@@ -0,0 +1,62 @@
+package probe
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNoReplies is returned by Sample when none of the probes came back.
+var ErrNoReplies = errors.New(`no replies`)
+
+// Sample sends count probes (waiting up to timeout for them to return) and reports the average round trip time.
+// The prober is started and stopped by Sample.
+func Sample(p Prober, count int, timeout time.Duration) (time.Duration, error) {
+	if err := p.Start(); err != nil {
+		return 0, err
+	}
+	defer p.Stop()
+
+	type probeID struct{ id, seq int }
+	pending := make(map[probeID]bool, count) // only the first count probes are measured
+	sent, recv := 0, 0
+	var total time.Duration
+	var last error
+
+	deadline := time.After(timeout)
+	for recv < count {
+		select {
+		case <-deadline:
+			return average(total, recv, last)
+		case ev, ok := <-p.Events():
+			if !ok {
+				return average(total, recv, last)
+			}
+			switch ev.Kind {
+			case Sent:
+				if sent < count {
+					sent++
+					pending[probeID{ev.ID, ev.Seq}] = true
+				}
+			case Received:
+				if pending[probeID{ev.ID, ev.Seq}] {
+					delete(pending, probeID{ev.ID, ev.Seq})
+					total += ev.Rtt
+					recv++
+				}
+			case Failed:
+				last = ev.Err
+			}
+		}
+	}
+	return average(total, recv, last)
+}
+
+func average(total time.Duration, n int, err error) (time.Duration, error) {
+	if n == 0 {
+		if err == nil {
+			err = ErrNoReplies
+		}
+		return 0, err
+	}
+	return total / time.Duration(n), nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package probe
+
+import "time"
+
+// enableTimestamps is a no-op where kernel receive timestamps aren't supported (user space timestamps are used instead).
+func enableTimestamps(syscallConn) error {
+	return nil
+}
+
+func kernelTime([]byte) (time.Time, bool) {
+	return time.Time{}, false
+}
@@ -0,0 +1,58 @@
+package probe
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+)
+
+// TCP measures how long it takes to establish a TCP connection (SYN → SYN/ACK).
+// Handy on networks that drop ICMP.
+type TCP struct {
+	base
+	addr    string        // host:port as given
+	timeout time.Duration // how long to wait for a handshake
+	dial    string        // resolved ip:port (so DNS isn't part of the measurement)
+}
+
+func newTCP(u *url.URL) (*TCP, error) {
+	if u.Port() == `` {
+		return nil, fmt.Errorf(`tcp target %q is missing a port`, u.Host)
+	}
+	timeout, err := durationParam(u.Query(), `timeout`, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return &TCP{base: newBase(u.Host), addr: u.Host, timeout: timeout}, nil
+}
+
+func (p *TCP) Start() error {
+	addr, err := net.ResolveTCPAddr(`tcp`, p.addr)
+	if err != nil {
+		return err
+	}
+	p.dial = addr.String()
+	p.loop(p.send)
+	return nil
+}
+
+func (p *TCP) send(seq int) {
+	sent, ok := p.launch(seq)
+	if !ok {
+		return
+	}
+	p.spawn(func() {
+		d := net.Dialer{Timeout: p.timeout}
+		conn, err := d.DialContext(p.ctx, `tcp`, p.dial)
+		now := time.Now()
+		if err != nil {
+			if p.ctx.Err() == nil {
+				p.emit(Event{Kind: Failed, ID: p.id, Seq: seq, Time: now, Err: err})
+			}
+			return
+		}
+		conn.Close()
+		p.emit(Event{Kind: Received, ID: p.id, Seq: seq, Time: now, Rtt: now.Sub(sent), TTL: -1})
+	})
+}
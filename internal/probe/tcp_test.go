@@ -0,0 +1,105 @@
+package probe
+
+import (
+	"net"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// collect starts p and gathers its events until it has n that aren't Sent (or gives up after a few seconds)
+func collect(t *testing.T, p Prober, n int) (events []Event) {
+	t.Helper()
+	p.SetInterval(20 * time.Millisecond)
+	if err := p.Start(); err != nil {
+		t.Fatalf(`start: %v`, err)
+	}
+	defer p.Stop()
+	timeout := time.After(5 * time.Second)
+	for got := 0; got < n; {
+		select {
+		case ev := <-p.Events():
+			events = append(events, ev)
+			if ev.Kind != Sent {
+				got++
+			}
+		case <-timeout:
+			t.Fatalf(`timed out with %d of %d events: %v`, got, n, events)
+		}
+	}
+	return events
+}
+
+func TestTCP(t *testing.T) {
+	ln, err := net.Listen(`tcp`, `127.0.0.1:0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	// a port nobody listens on (probably, it was free a moment ago)
+	closed, err := net.Listen(`tcp`, `127.0.0.1:0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed.Close()
+
+	tests := []struct {
+		name   string
+		target string
+		want   Kind
+	}{
+		{`listening`, `tcp://` + ln.Addr().String(), Received},
+		{`refused`, `tcp://` + closed.Addr().String(), Failed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := New(tt.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			events := collect(t, p, 3)
+			for i, ev := range events {
+				if ev.Kind == Sent {
+					continue
+				}
+				if ev.Kind != tt.want {
+					t.Errorf(`event %d: %s (%v), want %s`, i, ev.Kind, ev.Err, tt.want)
+				}
+				if ev.Kind == Received && (ev.Rtt <= 0 || ev.Rtt > time.Second) {
+					t.Errorf(`event %d: rtt %s`, i, ev.Rtt)
+				}
+			}
+		})
+	}
+}
+
+func TestTCPTargets(t *testing.T) {
+	tests := []struct {
+		target string
+		ok     bool
+	}{
+		{`tcp://127.0.0.1:443`, true},
+		{`tcp://127.0.0.1:443?timeout=1s`, true},
+		{`tcp://127.0.0.1`, false},
+		{`tcp://127.0.0.1:443?timeout=soon`, false},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := newTCP(u); (err == nil) != tt.ok {
+			t.Errorf(`%s: err = %v, want ok = %t`, tt.target, err, tt.ok)
+		}
+	}
+}
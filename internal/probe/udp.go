@@ -0,0 +1,220 @@
+package probe
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ReflectPort is the default port of `monet reflect`.
+const ReflectPort = `9798`
+
+// UDP probes a `monet reflect` server with sequence-numbered, timestamped datagrams.
+//
+// Knowing how many datagrams the reflector received lets us tell upstream loss from downstream loss,
+// and the reflector's timestamp splits every round trip into (clock offset skewed) one-way delays.
+// The offset doesn't change between probes, so the variation of each one-way delay is still meaningful.
+type UDP struct {
+	base
+	addr  string
+	token uint64 // identifies our datagrams on a shared reflector
+	conn  net.Conn
+
+	mu       sync.Mutex
+	inflight map[uint64]*datagram // by seq, for a minute after it was sent
+	sent     int                  // datagrams written to the socket
+	recv     int                  // datagrams answered (duplicate replies aside)
+	remote   uint64               // datagrams the reflector saw (as of the latest reply)
+	upTo     int                  // datagrams sent up to the one that latest reply answers
+	upMin    time.Duration        // smallest one-way delays seen so far (baseline for variation)
+	downMin  time.Duration
+	up, down time.Duration // latest one-way delay variations
+}
+
+// datagram is a probe sent to the reflector
+type datagram struct {
+	sent     time.Time
+	nth      int  // how many datagrams had been written with this one
+	answered bool // a reply came back (any more are duplicates)
+}
+
+func newUDP(u *url.URL) *UDP {
+	addr := u.Host
+	if u.Port() == `` {
+		addr = net.JoinHostPort(u.Hostname(), ReflectPort)
+	}
+	return &UDP{
+		base:     newBase(addr),
+		addr:     addr,
+		token:    rand.Uint64(),
+		inflight: make(map[uint64]*datagram),
+		upMin:    time.Duration(1<<63 - 1),
+		downMin:  time.Duration(1<<63 - 1),
+	}
+}
+
+func (p *UDP) Start() error {
+	conn, err := net.Dial(`udp`, p.addr)
+	if err != nil {
+		return err
+	}
+	p.conn = conn
+	p.wg.Add(1)
+	go p.read()
+	p.loop(p.send)
+	return nil
+}
+
+func (p *UDP) Stop() {
+	p.cancel()
+	if p.conn != nil {
+		p.conn.Close() // unblock the read loop
+	}
+	p.base.Stop()
+}
+
+func (p *UDP) send(seq int) {
+	sent, ok := p.launch(seq)
+	if !ok {
+		return
+	}
+	buf := reflection{token: p.token, seq: uint64(seq), sent: sent}.marshal()
+
+	// counted before writing, the reply can beat us back to the lock
+	p.mu.Lock()
+	p.sent++
+	p.inflight[uint64(seq)] = &datagram{sent: sent, nth: p.sent}
+	if seq%100 == 0 {
+		// replies this late are never coming back
+		for k, d := range p.inflight {
+			if sent.Sub(d.sent) > time.Minute {
+				delete(p.inflight, k)
+			}
+		}
+	}
+	p.mu.Unlock()
+
+	if _, err := p.conn.Write(buf); err != nil {
+		p.mu.Lock()
+		p.sent--
+		delete(p.inflight, uint64(seq))
+		p.mu.Unlock()
+		p.emit(Event{Kind: Failed, ID: p.id, Seq: seq, Err: fmt.Errorf(`on-send-err: %w`, err)})
+	}
+}
+
+func (p *UDP) read() {
+	defer p.wg.Done()
+	buf := make([]byte, 1500)
+	for {
+		n, err := p.conn.Read(buf)
+		now := time.Now()
+		if err != nil {
+			if p.ctx.Err() != nil {
+				return // closed by Stop
+			}
+			// ICMP port unreachable shows up here when the reflector isn't running
+			p.emit(Event{Kind: Failed, ID: p.id, Seq: -1, Err: fmt.Errorf(`on-recv-err: %w`, err)})
+			select { // don't spin on a persistent error
+			case <-p.ctx.Done():
+				return
+			case <-time.After(p.Interval()):
+			}
+			continue
+		}
+		r, ok := unmarshalReflection(buf[:n])
+		if !ok || r.token != p.token {
+			continue
+		}
+
+		// every reply is passed on, duplicates included (telling them apart is up to the listener)
+		p.mu.Lock()
+		d, ok := p.inflight[r.seq]
+		if !ok {
+			p.mu.Unlock()
+			continue // ancient reply
+		}
+		if !d.answered {
+			d.answered = true
+			p.recv++
+		}
+		if r.count >= p.remote {
+			p.remote, p.upTo = r.count, d.nth
+		}
+
+		// one-way delays include the (unknown) clock offset between hosts, so only their variation is reported
+		up, down := r.reflected.Sub(r.sent), now.Sub(r.reflected)
+		p.upMin, p.downMin = min(p.upMin, up), min(p.downMin, down)
+		p.up, p.down = up-p.upMin, down-p.downMin
+		phases := []Phase{{`up`, p.up}, {`down`, p.down}}
+		p.mu.Unlock()
+
+		p.emit(Event{Kind: Received, ID: p.id, Seq: int(r.seq), Time: now, Rtt: now.Sub(d.sent), TTL: -1, Phases: phases})
+	}
+}
+
+// Report describes loss in each direction and the latest one-way delay variation.
+func (p *UDP) Report() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// only datagrams sent before the one the reflector latest answered count, the ones after are still in flight
+	upLost := p.upTo - int(p.remote)
+	downLost := int(p.remote) - p.recv
+	return fmt.Sprintf(`upstream lost: %d (%s), downstream lost: %d (%s), up pdv: %.3fms, down pdv: %.3fms`,
+		max(upLost, 0), percent(upLost, p.upTo),
+		max(downLost, 0), percent(downLost, int(p.remote)),
+		float64(p.up.Microseconds())/1000, float64(p.down.Microseconds())/1000,
+	)
+}
+
+func percent(n, of int) string {
+	if of <= 0 || n <= 0 {
+		return `0.0%`
+	}
+	return fmt.Sprintf(`%.1f%%`, float64(n)*100/float64(of))
+}
+
+// reflection is the datagram exchanged with `monet reflect`.
+// The client fills in token, seq and sent; the reflector adds reflected and count before sending it back.
+type reflection struct {
+	token     uint64
+	seq       uint64
+	sent      time.Time // when the client sent it
+	reflected time.Time // when the reflector received it
+	count     uint64    // datagrams the reflector has received for this token (including this one)
+}
+
+var reflectMagic = [4]byte{'M', 'N', 'T', '1'}
+
+const reflectionSize = 4 + 8*5
+
+func (r reflection) marshal() []byte {
+	b := make([]byte, reflectionSize)
+	copy(b, reflectMagic[:])
+	binary.BigEndian.PutUint64(b[4:], r.token)
+	binary.BigEndian.PutUint64(b[12:], r.seq)
+	binary.BigEndian.PutUint64(b[20:], uint64(r.sent.UnixNano()))
+	if !r.reflected.IsZero() {
+		binary.BigEndian.PutUint64(b[28:], uint64(r.reflected.UnixNano()))
+	}
+	binary.BigEndian.PutUint64(b[36:], r.count)
+	return b
+}
+
+func unmarshalReflection(b []byte) (r reflection, ok bool) {
+	if len(b) != reflectionSize || [4]byte(b[:4]) != reflectMagic {
+		return r, false
+	}
+	r.token = binary.BigEndian.Uint64(b[4:])
+	r.seq = binary.BigEndian.Uint64(b[12:])
+	r.sent = time.Unix(0, int64(binary.BigEndian.Uint64(b[20:])))
+	if ns := int64(binary.BigEndian.Uint64(b[28:])); ns != 0 {
+		r.reflected = time.Unix(0, ns)
+	}
+	r.count = binary.BigEndian.Uint64(b[36:])
+	return r, true
+}
@@ -0,0 +1,101 @@
+package probe
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+// flaky is a reflector's socket that loses every other datagram on the way in, or sends every reply twice
+type flaky struct {
+	net.PacketConn
+	drop, dup bool
+	read      int
+}
+
+func (c *flaky) ReadFrom(b []byte) (int, net.Addr, error) {
+	for {
+		n, addr, err := c.PacketConn.ReadFrom(b)
+		if c.read++; err != nil || !c.drop || c.read%2 == 0 {
+			return n, addr, err
+		}
+	}
+}
+
+func (c *flaky) WriteTo(b []byte, addr net.Addr) (int, error) {
+	if c.dup {
+		c.PacketConn.WriteTo(b, addr)
+	}
+	return c.PacketConn.WriteTo(b, addr)
+}
+
+func TestUDP(t *testing.T) {
+	tests := []struct {
+		name      string
+		drop, dup bool
+		report    string // how it starts
+		repeats   bool   // some seq is received more than once
+	}{
+		{name: `clean`, report: `upstream lost: 0 (0.0%), downstream lost: 0 (0.0%)`},
+		{name: `upstream loss`, drop: true, report: `(50.0%), downstream lost: 0 (0.0%)`},
+		{name: `duplicates`, dup: true, report: `upstream lost: 0 (0.0%), downstream lost: 0 (0.0%)`, repeats: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := net.ListenPacket(`udp`, `127.0.0.1:0`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			go Reflect(&flaky{PacketConn: conn, drop: tt.drop, dup: tt.dup})
+
+			p, err := New(`udp://` + conn.LocalAddr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			seen := map[int]int{}
+			for i, ev := range collect(t, p, 6) {
+				if ev.Kind == Sent {
+					continue
+				}
+				if ev.Kind != Received {
+					t.Fatalf(`event %d: %s (%v), want %s`, i, ev.Kind, ev.Err, Received)
+				}
+				seen[ev.Seq]++
+				if tt.drop && ev.Seq%2 == 0 {
+					t.Errorf(`event %d: reply to dropped seq %d`, i, ev.Seq)
+				}
+			}
+			repeats := false
+			for _, n := range seen {
+				repeats = repeats || n > 1
+			}
+			if repeats != tt.repeats {
+				t.Errorf(`replies by seq %v, want repeats = %t`, seen, tt.repeats)
+			}
+			if report := p.(Reporter).Report(); !strings.Contains(report, tt.report) {
+				t.Errorf(`report %q, want %q`, report, tt.report)
+			}
+		})
+	}
+}
+
+func TestUDPReport(t *testing.T) {
+	tests := []struct {
+		name             string
+		sent, recv, upTo int
+		remote           uint64
+		want             string
+	}{
+		{`nothing yet`, 0, 0, 0, 0, `upstream lost: 0 (0.0%), downstream lost: 0 (0.0%)`},
+		{`in flight`, 10, 5, 5, 5, `upstream lost: 0 (0.0%), downstream lost: 0 (0.0%)`},
+		{`lost upstream`, 10, 4, 8, 4, `upstream lost: 4 (50.0%), downstream lost: 0 (0.0%)`},
+		{`lost downstream`, 10, 6, 8, 8, `upstream lost: 0 (0.0%), downstream lost: 2 (25.0%)`},
+	}
+	for _, tt := range tests {
+		p := &UDP{sent: tt.sent, recv: tt.recv, upTo: tt.upTo, remote: tt.remote}
+		if got := p.Report(); !strings.HasPrefix(got, tt.want) {
+			t.Errorf(`%s: %q, want %q`, tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// Package quality estimates how a voice or video call would fare over a network, from round trip times and loss.
+//
+// Jitter is RFC 3550's interarrival jitter and the score is ITU-T G.107's E-model, simplified the way most
+// monitoring tools do: G.711 with packet loss concealment, random (not bursty) loss and default values for
+// everything a ping can't see.
+package quality
+
+import "time"
+
+// Window is how much of the recent past an estimate looks at.
+const Window = 30 * time.Second
+
+// Jitter is the RFC 3550 interarrival jitter of consecutive round trips (oldest first).
+//
+// Without one way transit times, the difference between consecutive round trips stands in for D(i-1,i).
+func Jitter(rtts []time.Duration) time.Duration {
+	var j time.Duration
+	for i := 1; i < len(rtts); i++ {
+		j = Smooth(j, Change(rtts[i-1], rtts[i]))
+	}
+	return j
+}
+
+// Change is how much a round trip differs from the one before it.
+func Change(before, after time.Duration) time.Duration {
+	if after < before {
+		return before - after
+	}
+	return after - before
+}
+
+// Smooth moves the jitter estimate j a sixteenth of the way towards the latest change d, J += (|D| - J) / 16.
+func Smooth(j, d time.Duration) time.Duration {
+	return j + (d-j)/16
+}
+
+// Score is the E-model's verdict on a network.
+type Score struct {
+	Latency time.Duration // average round trip
+	Jitter  time.Duration
+	Loss    float64 // 0-1
+	R       float64 // transmission rating factor, 0 (unusable) to 93.2 (as good as it gets without wideband)
+	MOS     float64 // mean opinion score, 1 (bad) to 4.5 (excellent)
+}
+
+// E-model defaults for G.711 with packet loss concealment
+const (
+	r0  = 93.2 // basic signal to noise ratio, less the simultaneous impairments
+	bpl = 25.1 // packet loss robustness
+	ie  = 0    // equipment impairment (no compression)
+
+	codecDelay = 10 * time.Millisecond // packetization and the like
+)
+
+// Estimate scores a network given its average round trip, jitter and loss.
+func Estimate(latency, jitter time.Duration, loss float64) Score {
+	// one way delay, plus a jitter buffer twice the jitter
+	d := float64(latency/2+2*jitter+codecDelay) / float64(time.Millisecond)
+	id := 0.024 * d
+	if d > 177.3 {
+		id += 0.11 * (d - 177.3)
+	}
+	ppl := loss * 100
+	ieEff := ie + (95-ie)*ppl/(ppl+bpl)
+
+	s := Score{Latency: latency, Jitter: jitter, Loss: loss, R: max(r0-id-ieEff, 0)}
+	s.MOS = mos(s.R)
+	return s
+}
+
+// mos converts an R-factor to a mean opinion score (G.107 annex B)
+func mos(r float64) float64 {
+	switch {
+	case r <= 0:
+		return 1
+	case r >= 100:
+		return 4.5
+	}
+	return 1 + 0.035*r + r*(r-60)*(100-r)*7e-6
+}
+
+// Grade sums a score up in a word: good (R of 80 and up, users are satisfied), fair (60 and up, some are not) or poor.
+func (s Score) Grade() string {
+	switch {
+	case s.R >= 80:
+		return `good`
+	case s.R >= 60:
+		return `fair`
+	}
+	return `poor`
+}
@@ -0,0 +1,117 @@
+package quality
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+const ms = time.Millisecond
+
+func TestEstimate(t *testing.T) {
+	// one way delay plus jitter buffer and codec delay for 20ms, 2ms jitter: 10 + 4 + 10 = 24ms, Id = 0.024 * 24
+	const clean = r0 - 0.024*24
+	tests := []struct {
+		name    string
+		latency time.Duration
+		jitter  time.Duration
+		loss    float64
+		r, mos  float64
+		grade   string
+	}{
+		{name: `clean`, latency: 20 * ms, jitter: 2 * ms, r: clean, mos: 4.398, grade: `good`},
+		{name: `1% loss`, latency: 20 * ms, jitter: 2 * ms, loss: .01, r: clean - 95*1/(1+bpl), mos: 4.313, grade: `good`},
+		{name: `5% loss`, latency: 20 * ms, jitter: 2 * ms, loss: .05, r: clean - 95*5/(5+bpl), mos: 3.899, grade: `fair`},
+		{name: `under 177.3ms one way`, latency: 330 * ms, r: r0 - 0.024*175, mos: 4.314, grade: `good`},
+		{name: `over 177.3ms one way`, latency: 400 * ms, r: r0 - 0.024*210 - 0.11*(210-177.3), mos: 4.184, grade: `good`},
+		{name: `jitter counts twice`, latency: 20 * ms, jitter: 100 * ms, r: r0 - 0.024*220 - 0.11*(220-177.3), mos: 4.140, grade: `good`},
+		{name: `nothing gets through`, latency: 2 * time.Second, loss: 1, r: 0, mos: 1, grade: `poor`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Estimate(tt.latency, tt.jitter, tt.loss)
+			if math.Abs(s.R-tt.r) > 1e-9 || math.Abs(s.MOS-tt.mos) > 5e-4 || s.Grade() != tt.grade {
+				t.Errorf(`R %.4f, MOS %.4f (%s), want R %.4f, MOS %.3f (%s)`, s.R, s.MOS, s.Grade(), tt.r, tt.mos, tt.grade)
+			}
+			if s.Latency != tt.latency || s.Jitter != tt.jitter || s.Loss != tt.loss {
+				t.Errorf(`score of %v, %v and %g doesn't say so: %+v`, tt.latency, tt.jitter, tt.loss, s)
+			}
+		})
+	}
+
+	// the example everyone quotes: a quiet, nearby network is about as good as narrowband gets
+	if s := Estimate(20*ms, 2*ms, 0); math.Round(s.R*10)/10 != 92.6 || math.Round(s.MOS*10)/10 != 4.4 {
+		t.Errorf(`R %.1f, MOS %.1f, want about 92.6 and 4.4`, s.R, s.MOS)
+	}
+}
+
+func TestMOS(t *testing.T) {
+	tests := []struct {
+		r, mos float64
+	}{
+		{r: -10, mos: 1},
+		{r: 0, mos: 1},
+		{r: 50, mos: 2.575},
+		{r: 60, mos: 3.1},
+		{r: 80, mos: 4.024},
+		{r: 100, mos: 4.5},
+		{r: 120, mos: 4.5},
+	}
+	for _, tt := range tests {
+		if got := mos(tt.r); math.Abs(got-tt.mos) > 1e-9 {
+			t.Errorf(`mos(%g) = %g, want %g`, tt.r, got, tt.mos)
+		}
+	}
+}
+
+func TestGrade(t *testing.T) {
+	tests := []struct {
+		r     float64
+		grade string
+	}{
+		{r: 93.2, grade: `good`},
+		{r: 80, grade: `good`},
+		{r: 79.99, grade: `fair`},
+		{r: 60, grade: `fair`},
+		{r: 59.99, grade: `poor`},
+		{r: 0, grade: `poor`},
+	}
+	for _, tt := range tests {
+		if got := (Score{R: tt.r}).Grade(); got != tt.grade {
+			t.Errorf(`R %g is %s, want %s`, tt.r, got, tt.grade)
+		}
+	}
+}
+
+func TestJitter(t *testing.T) {
+	if got := Change(10*ms, 14*ms); got != 4*ms {
+		t.Errorf(`getting slower: %v, want 4ms`, got)
+	}
+	if got := Change(14*ms, 10*ms); got != 4*ms {
+		t.Errorf(`getting faster: %v, want 4ms`, got)
+	}
+	if got := Smooth(0, 16*ms); got != ms {
+		t.Errorf(`smoothing up: %v, want a sixteenth of the way (1ms)`, got)
+	}
+	if got := Smooth(16*ms, 0); got != 15*ms {
+		t.Errorf(`smoothing down: %v, want a sixteenth of the way (15ms)`, got)
+	}
+
+	tests := []struct {
+		name string
+		rtts []time.Duration
+		want time.Duration
+	}{
+		{name: `nothing`},
+		{name: `a single reply`, rtts: []time.Duration{20 * ms}},
+		{name: `steady`, rtts: []time.Duration{20 * ms, 20 * ms, 20 * ms}},
+		{name: `a spike`, rtts: []time.Duration{10 * ms, 26 * ms}, want: ms},
+		{name: `a spike and back`, rtts: []time.Duration{10 * ms, 26 * ms, 10 * ms}, want: ms + 15*ms/16},
+		{name: `back and forth`, rtts: []time.Duration{10 * ms, 26 * ms, 10 * ms, 26 * ms}, want: ms + 15*ms/16 + (16*ms-ms-15*ms/16)/16},
+	}
+	for _, tt := range tests {
+		if got := Jitter(tt.rtts); got != tt.want {
+			t.Errorf(`%s: %v, want %v`, tt.name, got, tt.want)
+		}
+	}
+}
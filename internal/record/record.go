@@ -0,0 +1,75 @@
+// Package record keeps a session's probe events in a JSON Lines file (one event per line).
+//
+// Everything on screen scrolls away and is gone on quit, a recording is the evidence to send to an ISP after a bad day.
+package record
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/bign8/monet/internal/probe"
+)
+
+// Event is a single line of a recording.
+type Event struct {
+	Time   time.Time  `json:"time"` // when it happened (when the reply arrived for received events)
+	Target string     `json:"target"`
+	Kind   string     `json:"kind"` // sent, received or failed
+	ID     int        `json:"id"`
+	Seq    int        `json:"seq"`
+	Sent   *time.Time `json:"sent,omitempty"`   // when the probe went out (received only)
+	Rtt    float64    `json:"rtt_ms,omitempty"` // round trip time in milliseconds (received only)
+	TTL    int        `json:"ttl,omitempty"`    // time to live of the reply (when known)
+	From   string     `json:"from,omitempty"`   // who answered
+	Err    string     `json:"error,omitempty"`  // what went wrong (failed only)
+}
+
+// New converts a probe event of target into a line of a recording.
+func New(target string, ev probe.Event) Event {
+	e := Event{
+		Time:   ev.Time,
+		Target: target,
+		Kind:   ev.Kind.String(),
+		ID:     ev.ID,
+		Seq:    ev.Seq,
+		TTL:    max(ev.TTL, 0),
+		From:   ev.From,
+	}
+	if ev.Kind == probe.Received {
+		sent := ev.Time.Add(-ev.Rtt)
+		e.Sent = &sent
+		e.Rtt = float64(ev.Rtt) / float64(time.Millisecond)
+	}
+	if ev.Err != nil {
+		e.Err = ev.Err.Error()
+	}
+	return e
+}
+
+// Probe converts a line of a recording back into the probe event it came from.
+func (e Event) Probe() probe.Event {
+	ev := probe.Event{
+		ID:   e.ID,
+		Seq:  e.Seq,
+		Time: e.Time,
+		Rtt:  time.Duration(math.Round(e.Rtt * float64(time.Millisecond))),
+		TTL:  e.TTL,
+		From: e.From,
+	}
+	if ev.TTL == 0 {
+		ev.TTL = -1
+	}
+	switch e.Kind {
+	case probe.Sent.String():
+		ev.Kind = probe.Sent
+	case probe.Received.String():
+		ev.Kind = probe.Received
+	default:
+		ev.Kind = probe.Failed
+	}
+	if e.Err != `` {
+		ev.Err = errors.New(e.Err)
+	}
+	return ev
+}
@@ -0,0 +1,113 @@
+package record
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Writer appends events to a recording, rotating it once it gets too big or too old.
+//
+// Rotated recordings keep their name with the time they were rotated added: session.jsonl becomes session-20060102T150405.000.jsonl
+// (session-20060102T150405.000-2.jsonl and so on when that's taken).
+type Writer struct {
+	path     string
+	maxBytes int64         // rotate once the file is this big (0 = never)
+	maxAge   time.Duration // rotate once the file has been written to for this long (0 = never)
+
+	file    *os.File
+	size    int64
+	started time.Time
+}
+
+// Create opens (or appends to) the recording at path.
+func Create(path string, maxBytes int64, maxAge time.Duration) (*Writer, error) {
+	w := &Writer{path: path, maxBytes: maxBytes, maxAge: maxAge}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Writer) open() error {
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.file, w.size, w.started = f, info.Size(), time.Now()
+	return nil
+}
+
+// Write appends a single event (a line is written in one go, so a crash never leaves half an event behind).
+func (w *Writer) Write(e Event) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	var rotateErr error
+	if w.full(int64(len(line)), e.Time) {
+		if rotateErr = w.rotate(); rotateErr != nil {
+			// keep going with the current file, trying again after another maxBytes or maxAge (not on every event)
+			rotateErr = fmt.Errorf(`rotate: %w`, rotateErr)
+			w.size, w.started = 0, e.Time
+		}
+	}
+	n, err := w.file.Write(line)
+	w.size += int64(n)
+	if err != nil {
+		return err
+	}
+	return rotateErr
+}
+
+// full reports whether the next n bytes (written at the given time) belong in a new file
+func (w *Writer) full(n int64, now time.Time) bool {
+	if w.size == 0 {
+		return false // an event is never too big for an empty file
+	}
+	return w.maxBytes > 0 && w.size+n > w.maxBytes || w.maxAge > 0 && now.Sub(w.started) >= w.maxAge
+}
+
+// rotate moves the current file out of the way and starts a fresh one.
+//
+// The current file is only closed once the fresh one is open, so whatever fails the writer always has a file to write to.
+func (w *Writer) rotate() error {
+	ext := filepath.Ext(w.path)
+	stamp := strings.TrimSuffix(w.path, ext) + `-` + time.Now().Format(`20060102T150405.000`)
+	rotated := stamp + ext
+	for n := 2; ; n++ { // rotating twice within a millisecond mustn't overwrite the first one
+		if _, err := os.Lstat(rotated); errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		rotated = fmt.Sprintf(`%s-%d%s`, stamp, n, ext)
+	}
+	if err := os.Rename(w.path, rotated); err != nil {
+		return err
+	}
+	old := w.file
+	if err := w.open(); err != nil {
+		return err // still appending to old (under its new name)
+	}
+	return old.Close()
+}
+
+// Close flushes the recording to disk.
+func (w *Writer) Close() error {
+	if err := w.file.Sync(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
@@ -0,0 +1,84 @@
+package record
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestWriterRotate(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxBytes int64
+		events   int               // a minute apart (so a minute old recording rotates on every one)
+		before   func(path string) // before the second event
+		failing  bool              // the second write reports the rotation failing
+		files    int               // in the directory afterwards
+		current  []int             // seqs left in the recording being written
+		rotated  []int             // seqs in the rotated recordings
+	}{
+		{name: `rotated`, events: 2, files: 2, current: []int{1}, rotated: []int{0}},
+		{name: `many within a millisecond`, maxBytes: 1, events: 5, files: 5, current: []int{4}, rotated: []int{0, 1, 2, 3}},
+		{name: `moved away`, events: 3, before: func(path string) { os.Remove(path) }, failing: true, files: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, `session.jsonl`)
+			maxAge := time.Minute
+			if tt.maxBytes > 0 {
+				maxAge = 0
+			}
+			w, err := Create(path, tt.maxBytes, maxAge)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer w.Close()
+
+			start := time.Now()
+			for seq := range tt.events {
+				at := start.Add(time.Duration(seq) * time.Minute)
+				if seq > 1 && tt.failing {
+					at = start.Add(time.Minute + time.Duration(seq)*time.Second) // a failed rotation isn't retried on every write
+				}
+				if seq == 1 && tt.before != nil {
+					tt.before(path)
+				}
+				if err := w.Write(Event{Time: at, Kind: `sent`, Seq: seq}); (err != nil) != (seq == 1 && tt.failing) {
+					t.Fatalf(`write %d: %v`, seq, err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != tt.files {
+				t.Errorf(`%d files, want %d`, len(entries), tt.files)
+			}
+			var current, rotated []int
+			for _, e := range entries {
+				events, err := Read(filepath.Join(dir, e.Name()))
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, ev := range events {
+					if e.Name() == `session.jsonl` {
+						current = append(current, ev.Seq)
+					} else {
+						rotated = append(rotated, ev.Seq)
+					}
+				}
+			}
+			slices.Sort(rotated)
+			if !slices.Equal(current, tt.current) || !slices.Equal(rotated, tt.rotated) {
+				t.Errorf(`current %v, rotated %v, want %v and %v`, current, rotated, tt.current, tt.rotated)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+// Package stats keeps monet's own statistics about round trip times.
+package stats
+
+import (
+	"math"
+	"time"
+)
+
+// Online tracks the count, mean and standard deviation of durations without storing them (Welford's online algorithm).
+//
+// Values are kept as float64 nanoseconds to avoid the time.Duration overflow described in doc/adr/0002-online-metrics.md.
+type Online struct {
+	Count int
+	mean  float64
+	m2    float64
+}
+
+// Add records a new duration.
+func (o *Online) Add(d time.Duration) {
+	o.Count++
+	delta1 := float64(d) - o.mean
+	o.mean += delta1 / float64(o.Count)
+	delta2 := float64(d) - o.mean
+	o.m2 += delta1 * delta2
+}
+
+// Mean is the average of all durations seen so far.
+func (o Online) Mean() time.Duration {
+	return time.Duration(o.mean)
+}
+
+// StdDev is the (population) standard deviation of all durations seen so far.
+func (o Online) StdDev() time.Duration {
+	if o.Count == 0 {
+		return 0
+	}
+	return time.Duration(math.Sqrt(o.m2 / float64(o.Count)))
+}
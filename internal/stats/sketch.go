@@ -0,0 +1,66 @@
+package stats
+
+import (
+	"math"
+	"time"
+)
+
+// Sketch estimates quantiles of durations in a fixed amount of memory (a DDSketch, see doc/adr/0005-quantile-sketch.md).
+//
+// Durations are counted in logarithmically sized buckets, so every estimate is within 1% of a duration that was actually seen.
+type Sketch struct {
+	Count int
+
+	zero    int   // durations too small to bucket (<= 1ns)
+	offset  int   // bucket index of counts[0]
+	buckets []int // counts per bucket, grown as needed
+}
+
+// relative accuracy of the sketch and the resulting ratio between neighboring buckets
+const (
+	accuracy = 0.01
+	gamma    = (1 + accuracy) / (1 - accuracy)
+)
+
+var logGamma = math.Log(gamma)
+
+// Add records a new duration.
+func (s *Sketch) Add(d time.Duration) {
+	s.Count++
+	if d <= 1 {
+		s.zero++
+		return
+	}
+	k := int(math.Ceil(math.Log(float64(d)) / logGamma))
+	switch {
+	case len(s.buckets) == 0:
+		s.offset = k
+		s.buckets = []int{0}
+	case k < s.offset:
+		s.buckets = append(make([]int, s.offset-k), s.buckets...)
+		s.offset = k
+	case k >= s.offset+len(s.buckets):
+		s.buckets = append(s.buckets, make([]int, k-s.offset-len(s.buckets)+1)...)
+	}
+	s.buckets[k-s.offset]++
+}
+
+// Quantile estimates the duration q (0 to 1) of the way through everything seen so far (0.5 = median).
+func (s Sketch) Quantile(q float64) time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	rank := int(min(max(q, 0), 1) * float64(s.Count-1))
+	seen := s.zero
+	if rank < seen {
+		return 0
+	}
+	for i, n := range s.buckets {
+		seen += n
+		if rank < seen {
+			// middle of the bucket (in relative terms) so the error is the same either way
+			return time.Duration(2 * math.Pow(gamma, float64(s.offset+i)) / (gamma + 1))
+		}
+	}
+	return 0 // unreachable, counts add up to Count
+}
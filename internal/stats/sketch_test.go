@@ -0,0 +1,83 @@
+package stats
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"time"
+)
+
+// within is true when got is within the sketch's relative accuracy of want
+func within(got, want time.Duration) bool {
+	return math.Abs(float64(got-want)) <= accuracy*float64(want)
+}
+
+func TestSketchQuantiles(t *testing.T) {
+	tests := []struct {
+		name string
+		gen  func(i int) time.Duration
+	}{
+		{`uniform`, func(i int) time.Duration { return time.Duration(i+1) * time.Microsecond }},
+		{`exponential`, func(int) time.Duration {
+			return time.Duration((rand.ExpFloat64() + 0.01) * float64(20*time.Millisecond))
+		}},
+		{`bimodal`, func(i int) time.Duration {
+			if i%10 == 0 {
+				return 250*time.Millisecond + time.Duration(i)*time.Microsecond
+			}
+			return 12*time.Millisecond + time.Duration(i)*time.Nanosecond
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s Sketch
+			exact := make([]time.Duration, 10000)
+			for i := range exact {
+				exact[i] = tt.gen(i)
+				s.Add(exact[i])
+			}
+			slices.Sort(exact)
+			if s.Count != len(exact) {
+				t.Errorf(`count %d, want %d`, s.Count, len(exact))
+			}
+			for _, q := range []float64{0, 0.5, 0.9, 0.95, 0.99, 0.995, 0.999, 0.9995, 1} {
+				want := exact[int(q*float64(len(exact)-1))]
+				if got := s.Quantile(q); !within(got, want) {
+					t.Errorf(`p%g = %s, want %s (±1%%)`, q*100, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSketchEdges(t *testing.T) {
+	tests := []struct {
+		name string
+		adds []time.Duration
+		want map[float64]time.Duration // quantile -> duration (±1%)
+	}{
+		{`empty`, nil, map[float64]time.Duration{0: 0, 0.5: 0, 1: 0}},
+		{`zero bucket`, []time.Duration{0, 1, 0, time.Millisecond}, map[float64]time.Duration{0: 0, 0.5: 0, 1: time.Millisecond}},
+		{`grows down`, []time.Duration{time.Millisecond, time.Microsecond, 10 * time.Nanosecond}, map[float64]time.Duration{0: 10, 0.5: time.Microsecond, 1: time.Millisecond}},
+		{`grows up`, []time.Duration{time.Millisecond, time.Second, time.Hour}, map[float64]time.Duration{0: time.Millisecond, 0.5: time.Second, 1: time.Hour}},
+		{`grows both ways`, []time.Duration{time.Second, time.Microsecond, time.Hour, time.Millisecond, time.Second}, map[float64]time.Duration{0: time.Microsecond, 0.25: time.Millisecond, 0.5: time.Second, 1: time.Hour}},
+		{`out of range quantiles`, []time.Duration{time.Millisecond, time.Second}, map[float64]time.Duration{-1: time.Millisecond, 2: time.Second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s Sketch
+			for _, d := range tt.adds {
+				s.Add(d)
+			}
+			if s.Count != len(tt.adds) {
+				t.Errorf(`count %d, want %d`, s.Count, len(tt.adds))
+			}
+			for q, want := range tt.want {
+				if got := s.Quantile(q); !within(got, want) {
+					t.Errorf(`quantile %g = %s, want %s (±1%%)`, q, got, want)
+				}
+			}
+		})
+	}
+}
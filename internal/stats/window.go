@@ -0,0 +1,87 @@
+package stats
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Window tracks the mean and standard deviation of only the most recent durations.
+//
+// Lifetime statistics (Online) barely move after a day of pinging, a window shows what the connection is doing now.
+// Samples are dropped once there are more than Size of them or they are older than Age (zero disables either limit).
+type Window struct {
+	Size int
+	Age  time.Duration
+
+	samples []sample
+}
+
+type sample struct {
+	at time.Time
+	d  time.Duration
+}
+
+// Add records a duration that arrived at the given time, dropping whatever fell out of the window.
+func (w *Window) Add(at time.Time, d time.Duration) {
+	w.samples = append(w.samples, sample{at: at, d: d})
+	drop := 0
+	if w.Size > 0 && len(w.samples) > w.Size {
+		drop = len(w.samples) - w.Size
+	}
+	if w.Age > 0 {
+		for drop < len(w.samples) && at.Sub(w.samples[drop].at) > w.Age {
+			drop++
+		}
+	}
+	w.samples = w.samples[drop:]
+}
+
+// Count is the number of durations in the window.
+func (w Window) Count() int {
+	return len(w.samples)
+}
+
+// Mean is the average of the durations in the window.
+func (w Window) Mean() time.Duration {
+	return time.Duration(w.mean())
+}
+
+func (w Window) mean() float64 {
+	if len(w.samples) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, s := range w.samples {
+		sum += float64(s.d)
+	}
+	return sum / float64(len(w.samples))
+}
+
+// StdDev is the (population) standard deviation of the durations in the window.
+// Computed in two passes over float64s, see doc/adr/0002-online-metrics.md for why not time.Duration.
+func (w Window) StdDev() time.Duration {
+	if len(w.samples) == 0 {
+		return 0
+	}
+	mean := w.mean()
+	m2 := 0.0
+	for _, s := range w.samples {
+		delta := float64(s.d) - mean
+		m2 += delta * delta
+	}
+	return time.Duration(math.Sqrt(m2 / float64(len(w.samples))))
+}
+
+// String describes the limits of the window (`last 1000`, `last 5m0s`).
+func (w Window) String() string {
+	switch {
+	case w.Size > 0 && w.Age > 0:
+		return fmt.Sprintf(`last %d or %s`, w.Size, w.Age)
+	case w.Size > 0:
+		return fmt.Sprintf(`last %d`, w.Size)
+	case w.Age > 0:
+		return fmt.Sprintf(`last %s`, w.Age)
+	}
+	return `everything`
+}
@@ -0,0 +1,43 @@
+package stats
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindow(t *testing.T) {
+	start := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	ms := time.Millisecond
+	tests := []struct {
+		name   string
+		window Window
+		adds   []time.Duration // a second apart
+		count  int
+		mean   time.Duration
+		stdDev time.Duration
+		text   string
+	}{
+		{`empty`, Window{Size: 3}, nil, 0, 0, 0, `last 3`},
+		{`everything`, Window{}, []time.Duration{10 * ms, 20 * ms, 30 * ms, 40 * ms}, 4, 25 * ms, 11180339, `everything`},
+		{`by count`, Window{Size: 2}, []time.Duration{100 * ms, 10 * ms, 20 * ms}, 2, 15 * ms, 5 * ms, `last 2`},
+		{`by age`, Window{Age: 2 * time.Second}, []time.Duration{100 * ms, 10 * ms, 20 * ms, 30 * ms, 40 * ms}, 3, 30 * ms, 8164965, `last 2s`},
+		{`age right on the edge stays`, Window{Age: 4 * time.Second}, []time.Duration{10 * ms, 20 * ms, 30 * ms, 40 * ms, 50 * ms}, 5, 30 * ms, 14142135, `last 4s`},
+		{`count before age`, Window{Size: 2, Age: time.Minute}, []time.Duration{100 * ms, 10 * ms, 20 * ms}, 2, 15 * ms, 5 * ms, `last 2 or 1m0s`},
+		{`age before count`, Window{Size: 10, Age: time.Second}, []time.Duration{100 * ms, 10 * ms, 20 * ms}, 2, 15 * ms, 5 * ms, `last 10 or 1s`},
+		{`steady`, Window{Size: 10}, []time.Duration{7 * ms, 7 * ms, 7 * ms}, 3, 7 * ms, 0, `last 10`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := tt.window
+			for i, d := range tt.adds {
+				w.Add(start.Add(time.Duration(i)*time.Second), d)
+			}
+			if w.Count() != tt.count || w.Mean() != tt.mean || w.StdDev() != tt.stdDev {
+				t.Errorf(`count/mean/sd = %d/%s/%s, want %d/%s/%s`, w.Count(), w.Mean(), w.StdDev(), tt.count, tt.mean, tt.stdDev)
+			}
+			if w.String() != tt.text {
+				t.Errorf(`described as %q, want %q`, w.String(), tt.text)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+// Package triage figures out whose fault it is when the network misbehaves: the LAN, the ISP or the target.
+package triage
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bign8/monet/internal/probe"
+)
+
+// ErrNoGateway is returned when there is no default route.
+var ErrNoGateway = errors.New(`no default gateway`)
+
+// Gateway finds the default gateway from the kernel's routing table (linux only).
+func Gateway(v6 bool) (string, error) {
+	if v6 {
+		return gateway6(`/proc/net/ipv6_route`)
+	}
+	return gateway4(`/proc/net/route`)
+}
+
+// gateway4 parses /proc/net/route, where addresses are little endian hex:
+//
+//	Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+//	eth0	00000000	010200C0	0003	0	0	0	00000000	0	0	0
+func gateway4(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return ``, err
+	}
+	defer f.Close()
+
+	const rtfGateway = 0x2
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // skip header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 || fields[1] != `00000000` || fields[7] != `00000000` {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[3], 16, 16)
+		if err != nil || flags&rtfGateway == 0 {
+			continue
+		}
+		gw, err := strconv.ParseUint(fields[2], 16, 32)
+		if err != nil {
+			continue
+		}
+		return net.IPv4(byte(gw), byte(gw>>8), byte(gw>>16), byte(gw>>24)).String(), nil
+	}
+	if err := scanner.Err(); err != nil {
+		return ``, err
+	}
+	return ``, ErrNoGateway
+}
+
+// gateway6 parses /proc/net/ipv6_route, where addresses are big endian hex:
+//
+//	destination prefix-length source prefix-length next-hop metric refcnt use flags iface
+func gateway6(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return ``, err
+	}
+	defer f.Close()
+
+	const zero = `00000000000000000000000000000000`
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || fields[0] != zero || fields[1] != `00` || fields[4] == zero {
+			continue
+		}
+		b, err := hex.DecodeString(fields[4])
+		if err != nil || len(b) != 16 {
+			continue
+		}
+		addr := netip.AddrFrom16([16]byte(b))
+		if addr.IsLinkLocalUnicast() {
+			addr = addr.WithZone(fields[9]) // link local gateways need to know which interface they're on
+		}
+		return addr.String(), nil
+	}
+	if err := scanner.Err(); err != nil {
+		return ``, err
+	}
+	return ``, ErrNoGateway
+}
+
+// maxHops is how far down the path we look for the ISP.
+const maxHops = 8
+
+// ISPHop finds the first router on the path to target that isn't on a private network.
+// The hops are queried in parallel, so this takes about timeout regardless of the length of the path.
+func ISPHop(target string, timeout time.Duration) (string, error) {
+	type hop struct {
+		addr    string
+		reached bool
+		err     error
+	}
+	hops := make([]hop, maxHops+1)
+	var wg sync.WaitGroup
+	for ttl := 2; ttl <= maxHops; ttl++ { // 1 is the gateway
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			addr, reached, err := probe.Hop(target, ttl, timeout)
+			hops[ttl] = hop{addr, reached, err}
+		}()
+	}
+	wg.Wait()
+
+	var errs []error
+	for ttl := 2; ttl <= maxHops; ttl++ {
+		h := hops[ttl]
+		if h.err != nil {
+			if !errors.Is(h.err, probe.ErrNoReplies) {
+				errs = append(errs, fmt.Errorf(`hop %d: %w`, ttl, h.err))
+			}
+			continue // routers that don't answer are just as likely at the ISP as anywhere else
+		}
+		if h.reached {
+			break
+		}
+		if addr, err := netip.ParseAddr(h.addr); err == nil && public(addr) {
+			return h.addr, nil
+		}
+	}
+	if len(errs) > 0 {
+		return ``, errors.Join(errs...)
+	}
+	return ``, errors.New(`no public hop found before the target`)
+}
+
+// public is true for addresses outside of private, loopback and link local ranges (carrier grade NAT belongs to the ISP).
+func public(addr netip.Addr) bool {
+	return !addr.IsPrivate() && !addr.IsLoopback() && !addr.IsLinkLocalUnicast() && !addr.IsUnspecified()
+}
+
+// Health summarizes how a hop has been doing recently.
+type Health struct {
+	Answered int // probes that came back in time
+	Lost     int // probes that didn't
+}
+
+// minProbes is how many probes a hop needs before we're willing to judge it.
+const minProbes = 5
+
+func (h Health) judged() bool {
+	return h.Answered+h.Lost >= minProbes
+}
+
+func (h Health) ok() bool {
+	return h.judged() && h.Lost == 0
+}
+
+func (h Health) String() string {
+	switch {
+	case !h.judged():
+		return `checking`
+	case h.Lost == 0:
+		return `ok`
+	case h.Answered == 0:
+		return `unreachable`
+	}
+	return fmt.Sprintf(`losing %.0f%%`, float64(h.Lost)*100/float64(h.Answered+h.Lost))
+}
+
+// Verdict describes every hop and blames the first one in trouble.
+// The ISP hop is left out when it couldn't be found (ispKnown = false).
+func Verdict(lan, isp, target Health, ispKnown bool) (verdict string, blame bool) {
+	type hop struct {
+		name   string
+		health Health
+		fault  string
+	}
+	hops := []hop{
+		{`LAN`, lan, `looks like your local network`},
+		{`ISP hop`, isp, `looks like your ISP`},
+		{`target`, target, `looks like the target (or somewhere past your ISP)`},
+	}
+	if !ispKnown {
+		hops = slices.Delete(hops, 1, 2)
+	}
+
+	parts := make([]string, len(hops))
+	for i, h := range hops {
+		parts[i] = h.name + ` ` + h.health.String()
+	}
+	verdict = strings.Join(parts, `, `)
+
+	for _, h := range hops {
+		if !h.health.judged() {
+			return verdict, false
+		}
+		if !h.health.ok() {
+			return verdict + ` → ` + h.fault, true
+		}
+	}
+	return verdict + ` → all good`, false
+}
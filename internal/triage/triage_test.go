@@ -0,0 +1,164 @@
+package triage
+
+import (
+	"errors"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fixture writes contents to a file, returning its path
+func fixture(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), `route`)
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGateway4(t *testing.T) {
+	const header = "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT\n"
+	tests := []struct {
+		name  string
+		table string
+		want  string
+		err   error
+	}{
+		{
+			name: `default route`,
+			table: header +
+				"eth0\t0002A8C0\t00000000\t0001\t0\t0\t100\t00FFFFFF\t0\t0\t0\n" +
+				"eth0\t00000000\t0102A8C0\t0003\t0\t0\t100\t00000000\t0\t0\t0\n",
+			want: `192.168.2.1`,
+		},
+		{
+			name: `first default route wins`,
+			table: header +
+				"wlan0\t00000000\t010010AC\t0003\t0\t0\t600\t00000000\t0\t0\t0\n" +
+				"eth0\t00000000\t0102A8C0\t0003\t0\t0\t100\t00000000\t0\t0\t0\n",
+			want: `172.16.0.1`,
+		},
+		{
+			name: `default route without a gateway`,
+			table: header +
+				"wg0\t00000000\t00000000\t0001\t0\t0\t0\t00000000\t0\t0\t0\n",
+			err: ErrNoGateway,
+		},
+		{
+			name:  `no routes`,
+			table: header,
+			err:   ErrNoGateway,
+		},
+		{
+			name: `garbage`,
+			table: header +
+				"eth0\t00000000\tnothex\t0003\t0\t0\t100\t00000000\t0\t0\t0\n" +
+				"eth0\t00000000\n",
+			err: ErrNoGateway,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := gateway4(fixture(t, tt.table))
+			if got != tt.want || !errors.Is(err, tt.err) {
+				t.Errorf(`got %q, %v, want %q, %v`, got, err, tt.want, tt.err)
+			}
+		})
+	}
+}
+
+func TestGateway6(t *testing.T) {
+	tests := []struct {
+		name  string
+		table string
+		want  string
+		err   error
+	}{
+		{
+			name: `link local next hop`,
+			table: "" +
+				"20010db8000000000000000000000000 40 00000000000000000000000000000000 00 00000000000000000000000000000000 00000100 00000001 00000000 00000001     eth0\n" +
+				"00000000000000000000000000000000 00 00000000000000000000000000000000 00 fe80000000000000021122fffe334455 00000400 00000001 00000000 00000003     eth0\n",
+			want: `fe80::211:22ff:fe33:4455%eth0`,
+		},
+		{
+			name: `global next hop`,
+			table: "" +
+				"00000000000000000000000000000000 00 00000000000000000000000000000000 00 20010db8000000000000000000000001 00000400 00000001 00000000 00000003     eth0\n",
+			want: `2001:db8::1`,
+		},
+		{
+			name: `default route without a next hop`,
+			table: "" +
+				"00000000000000000000000000000000 00 00000000000000000000000000000000 00 00000000000000000000000000000000 ffffffff 00000001 00000000 00200200       lo\n",
+			err: ErrNoGateway,
+		},
+		{
+			name:  `no routes`,
+			table: ``,
+			err:   ErrNoGateway,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := gateway6(fixture(t, tt.table))
+			if got != tt.want || !errors.Is(err, tt.err) {
+				t.Errorf(`got %q, %v, want %q, %v`, got, err, tt.want, tt.err)
+			}
+		})
+	}
+}
+
+func TestPublic(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{`1.1.1.1`, true},
+		{`100.64.0.1`, true}, // carrier grade NAT is the ISP's
+		{`192.168.1.1`, false},
+		{`10.0.0.1`, false},
+		{`127.0.0.1`, false},
+		{`169.254.1.1`, false},
+		{`2606:4700:4700::1111`, true},
+		{`fe80::1`, false},
+		{`fd00::1`, false},
+		{`::`, false},
+	}
+	for _, tt := range tests {
+		if got := public(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf(`public(%s) = %t, want %t`, tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestVerdict(t *testing.T) {
+	ok := Health{Answered: 10}
+	down := Health{Lost: 10}
+	lossy := Health{Answered: 8, Lost: 2}
+	early := Health{Answered: 2}
+	tests := []struct {
+		name          string
+		lan, isp, dst Health
+		ispKnown      bool
+		want          string
+		blame         bool
+	}{
+		{`all good`, ok, ok, ok, true, `LAN ok, ISP hop ok, target ok → all good`, false},
+		{`gateway down`, down, down, down, true, `LAN unreachable, ISP hop unreachable, target unreachable → looks like your local network`, true},
+		{`upstream down`, ok, down, down, true, `LAN ok, ISP hop unreachable, target unreachable → looks like your ISP`, true},
+		{`only the target (like a DNS resolver) down`, ok, ok, lossy, true, `LAN ok, ISP hop ok, target losing 20% → looks like the target (or somewhere past your ISP)`, true},
+		{`isp unknown`, ok, Health{}, down, false, `LAN ok, target unreachable → looks like the target (or somewhere past your ISP)`, true},
+		{`still checking`, ok, early, down, true, `LAN ok, ISP hop checking, target unreachable`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, blame := Verdict(tt.lan, tt.isp, tt.dst, tt.ispKnown)
+			if got != tt.want || blame != tt.blame {
+				t.Errorf(`got %q, %t, want %q, %t`, got, blame, tt.want, tt.blame)
+			}
+		})
+	}
+}
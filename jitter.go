@@ -0,0 +1,35 @@
+package main
+
+import (
+	"github.com/bign8/monet/internal/chart"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// chartMode is what the chart plots (cycled through with the chart key)
+type chartMode int
+
+const (
+	latencyChart chartMode = iota // round trips, with the average and deviation lines
+	jitterChart                   // change in round trip between replies, with RFC 3550 jitter smoothing it out
+	chartModes                    // how many there are
+)
+
+// next is the mode after this one (wrapping around)
+func (c chartMode) next() chartMode {
+	return (c + 1) % chartModes
+}
+
+// point is how the mode converts a column into a chart point
+func (c chartMode) point() func(column) chart.Point {
+	if c == jitterChart {
+		return column.changePoint
+	}
+	return column.point
+}
+
+// jitterLines are the reference lines of the jitter chart: the focused target's RFC 3550 jitter
+func jitterLines(cols []column) []chart.Line {
+	return []chart.Line{
+		{Points: chartPoints(cols, column.jitterPoint), Color: lipgloss.Color(`11`), Legend: `RFC 3550 jitter`},
+	}
+}
@@ -1,23 +1,30 @@
 package main
 
 import (
-	"errors"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"log/slog"
 	"math"
-	"math/rand/v2"
+	"net"
+	"net/http"
 	"os"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/bign8/monet/internal/alert"
+	"github.com/bign8/monet/internal/chart"
+	"github.com/bign8/monet/internal/metrics"
+	"github.com/bign8/monet/internal/probe"
+	"github.com/bign8/monet/internal/record"
+	"github.com/bign8/monet/internal/stats"
 	"github.com/charmbracelet/bubbles/help"
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
-	"github.com/guptarohit/asciigraph"
-	probing "github.com/prometheus-community/pro-bing"
 )
 
 func chk(name string, err error) {
@@ -27,71 +34,199 @@ func chk(name string, err error) {
 	}
 }
 
+// what to monitor when nothing is given on the command line
+const defaultTarget = `2606:4700:4700::1111`
+
+// limits of every target's recent statistics (see --window)
+var recent = stats.Window{Size: 1000}
+
+// when to raise the alarm (see --rules)
+var rules = alert.Defaults
+
+// replies slower than this count towards incidents (see --slow)
+var slow = 90 * time.Millisecond
+
 func main() {
-	target := `2606:4700:4700::1111`
-	if len(os.Args) > 1 {
-		target = os.Args[1]
+	flag.Func(`window`, "how much of the recent past drives the average and deviation lines, a number of pings or a duration (default 1000)", func(v string) (err error) {
+		recent, err = parseWindow(v)
+		return err
+	})
+	flag.Func(`rules`, "file of alert rules, a rule per line like: critical loss > 5% over 30s clear loss < 1% over 1m", func(path string) (err error) {
+		rules, err = alert.Load(path)
+		return err
+	})
+	call := flag.Bool(`call`, false, "alert on estimated call quality (fair warns, poor is critical) instead of latency, unless --rules is given")
+	flag.DurationVar(&slow, `slow`, slow, "replies slower than this count towards incidents, like lost probes do (0 = only lost probes)")
+	column := flag.Duration(`column`, 0, "wall-clock time per chart column, so the chart's scale doesn't change with the interval (default a probe per column)")
+	recording := flag.String(`record`, ``, "write every probe event to this JSON Lines file (session.jsonl)")
+	rotateMB := flag.Int64(`rotate-mb`, 0, "start a new recording once it reaches this many megabytes (0 = never)")
+	rotateEvery := flag.Duration(`rotate-every`, 0, "start a new recording this often (0 = never)")
+	listen := flag.String(`listen`, ``, "serve prometheus metrics on this address (:9797)")
+	summaryJSON := flag.String(`summary-json`, ``, "also write the summary printed on quitting to this file as JSON (- for stdout)")
+	bell := flag.Bool(`bell`, false, "ring the terminal bell when an alert fires")
+	hook := flag.String(`exec`, ``, "shell command to run when an alert fires or clears (details in MONET_TARGET, MONET_SEVERITY, MONET_RULE, MONET_STATE, ...)")
+	webhook := flag.String(`webhook`, ``, "URL to POST a JSON notice to when an alert fires or clears")
+	socket := flag.String(`socket`, socketPath(), "unix socket the daemon listens on (for monet attach)")
+	flag.Parse()
+	ruled := false
+	flag.Visit(func(f *flag.Flag) { ruled = ruled || f.Name == `rules` })
+	if *call && !ruled {
+		rules = alert.CallQuality
+	}
+
+	targets := []string{defaultTarget}
+	if flag.NArg() > 0 {
+		targets = flag.Args()
+	}
+
+	var all []series
+	var path *hops
+	var trace *route
+	var playback *replay
+	var headless bool
+	var frames *json.Decoder // attached to a daemon
+	var conn net.Conn
+	switch targets[0] {
+	case `reflect`:
+		reflect(targets[1:])
+		return
+	case `mtr`:
+		target := `1.1.1.1`
+		if len(targets) > 1 {
+			target = targets[1]
+		}
+		all, trace = mtrTargets(target)
+	case `replay`:
+		all, playback = replayTargets(targets[1:])
+	case `triage`:
+		target := `1.1.1.1`
+		if len(targets) > 1 {
+			target = targets[1]
+		}
+		all, path = triageTargets(target)
+	case `attach`:
+		sock := *socket
+		if len(targets) > 1 {
+			sock = targets[1]
+		}
+		var e engine
+		e, frames, conn = attach(sock)
+		all = e.targets
+	case `daemon`:
+		headless = true
+		targets = targets[1:]
+		if len(targets) == 0 {
+			targets = []string{defaultTarget}
+		}
+		fallthrough
+	default:
+		for _, target := range targets {
+			all = append(all, newSeries(``, target))
+		}
+	}
+
+	e := engine{targets: all, actions: alert.Actions{Exec: *hook, Webhook: *webhook}}
+	if *recording != `` {
+		rec, err := record.Create(*recording, *rotateMB<<20, *rotateEvery)
+		chk(`Error creating recording`, err)
+		e.rec = rec
+	}
+
+	if *listen != `` {
+		ln, err := net.Listen(`tcp`, *listen)
+		chk(`Error listening`, err)
+		e.metrics = metrics.New()
+		mux := http.NewServeMux()
+		mux.Handle(`/metrics`, e.metrics)
+		go func() { _ = http.Serve(ln, mux) }() // nowhere to report errors once probing is underway
+	}
+
+	if headless {
+		daemon(e, *socket)
+		return
 	}
 
 	// clockwise spinning dots
 	slices.Reverse(spinner.Dot.Frames)
 
 	m := model{
-		keys: keyMap{
-			Fast: key.NewBinding(
-				key.WithKeys(`f`),
-				key.WithHelp(`f`, `Faster`),
-			),
-			Slow: key.NewBinding(
-				key.WithKeys(`s`),
-				key.WithHelp(`s`, `Slower`),
-			),
-			Help: key.NewBinding(
-				key.WithKeys(`?`),
-				key.WithHelp(`?`, `Help`),
-			),
-			Quit: key.NewBinding(
-				key.WithKeys(`q`, `esc`, `ctrl+c`),
-				key.WithHelp(`q`, `quit`),
-			),
-			Debug: key.NewBinding(
-				key.WithKeys(`d`),
-				key.WithHelp(`d`, `Toggle Debug`),
-			),
-			Warn: key.NewBinding(
-				key.WithKeys(`w`),
-				key.WithHelp(`w`, `Toggle Warning`),
-			),
-			Fail: key.NewBinding(
-				key.WithKeys(`e`),
-				key.WithHelp(`e`, `Toggle Error`),
-			),
-			ClearWarn: key.NewBinding(
-				key.WithKeys(`W`),
-				key.WithHelp(`W`, `Clear Warning`),
-			),
-			ClearFail: key.NewBinding(
-				key.WithKeys(`E`),
-				key.WithHelp(`E`, `Clear Error`),
-			),
-		},
-		help: help.New(),
-		ping: probing.New(target),
-		spin: spinner.New(spinner.WithSpinner(spinner.Dot)),
+		keys:   newKeyMap(),
+		engine: e,
+		help:   help.New(),
+		triage: path,
+		mtr:    trace,
+		spin:   spinner.New(spinner.WithSpinner(spinner.Dot)),
+		speedX: 1,
+		column: *column,
+		bell:   *bell,
+		replay: playback,
+		frames: frames,
+	}
+	if playback != nil || frames != nil {
+		// the recording (or the daemon) decides how often things are probed
+		m.changed = true
+		for _, k := range []*key.Binding{&m.keys.Fast, &m.keys.Slow} {
+			k.SetEnabled(false)
+		}
+	}
+	if playback != nil {
+		for _, k := range []*key.Binding{&m.keys.Play, &m.keys.Speed, &m.keys.Slowdown, &m.keys.Forward, &m.keys.Back} {
+			k.SetEnabled(true)
+		}
+	}
+	if trace != nil {
+		// routers don't appreciate being hammered, go mtr's pace from the start
+		m.speedX, m.changed = len(intervals)-1, true
 	}
 
 	p := tea.NewProgram(m)
 
-	_, err := p.Run()
+	final, err := p.Run()
 	chk(`Error running program`, err)
+	m = final.(model)
+	if conn != nil {
+		conn.Close()
+	}
+	if m.rec != nil {
+		chk(`Error closing recording`, m.rec.Close())
+	}
+
+	report := m.report()
+	fmt.Print(report.String())
+	if *summaryJSON != `` {
+		chk(`Error writing summary`, report.write(*summaryJSON))
+	}
+}
+
+// reflect runs the server side of `udp://` targets: `monet reflect [address]`
+func reflect(args []string) {
+	addr := `:` + probe.ReflectPort
+	if len(args) > 0 {
+		addr = args[0]
+	}
+	conn, err := net.ListenPacket(`udp`, addr)
+	chk(`Error listening`, err)
+	slog.Info(`reflecting`, `address`, conn.LocalAddr().String())
+	chk(`Error reflecting`, probe.Reflect(conn))
 }
 
 type keyMap struct {
-	Fast  key.Binding
-	Slow  key.Binding
-	Help  key.Binding
-	Quit  key.Binding
-	Debug key.Binding
+	Fast      key.Binding
+	Slow      key.Binding
+	Help      key.Binding
+	Incidents key.Binding
+	Chart     key.Binding
+	Quit      key.Binding
+	Debug     key.Binding
+	Focus     key.Binding
+
+	Lifetime key.Binding
+
+	Play     key.Binding
+	Speed    key.Binding
+	Slowdown key.Binding
+	Forward  key.Binding
+	Back     key.Binding
 
 	Warn      key.Binding
 	Fail      key.Binding
@@ -99,58 +234,180 @@ type keyMap struct {
 	ClearFail key.Binding
 }
 
+// newKeyMap binds every key (the replay ones start out disabled)
+func newKeyMap() keyMap {
+	return keyMap{
+		Fast: key.NewBinding(
+			key.WithKeys(`f`),
+			key.WithHelp(`f`, `Faster`),
+		),
+		Slow: key.NewBinding(
+			key.WithKeys(`s`),
+			key.WithHelp(`s`, `Slower`),
+		),
+		Help: key.NewBinding(
+			key.WithKeys(`?`),
+			key.WithHelp(`?`, `Help`),
+		),
+		Incidents: key.NewBinding(
+			key.WithKeys(`i`),
+			key.WithHelp(`i`, `incidents`),
+		),
+		Quit: key.NewBinding(
+			key.WithKeys(`q`, `esc`, `ctrl+c`),
+			key.WithHelp(`q`, `quit`),
+		),
+		Debug: key.NewBinding(
+			key.WithKeys(`d`),
+			key.WithHelp(`d`, `Toggle Debug`),
+		),
+		Warn: key.NewBinding(
+			key.WithKeys(`w`),
+			key.WithHelp(`w`, `Toggle Warning`),
+		),
+		Fail: key.NewBinding(
+			key.WithKeys(`e`),
+			key.WithHelp(`e`, `Toggle Error`),
+		),
+		ClearWarn: key.NewBinding(
+			key.WithKeys(`W`),
+			key.WithHelp(`W`, `Clear Warning`),
+		),
+		ClearFail: key.NewBinding(
+			key.WithKeys(`E`),
+			key.WithHelp(`E`, `Clear Error`),
+		),
+		Focus: key.NewBinding(
+			key.WithKeys(`tab`),
+			key.WithHelp(`tab`, `Next Target`),
+		),
+		Play: key.NewBinding(
+			key.WithKeys(` `),
+			key.WithHelp(`space`, `Play/Pause`),
+			key.WithDisabled(),
+		),
+		Speed: key.NewBinding(
+			key.WithKeys(`>`, `.`),
+			key.WithHelp(`>`, `Replay Faster`),
+			key.WithDisabled(),
+		),
+		Slowdown: key.NewBinding(
+			key.WithKeys(`<`, `,`),
+			key.WithHelp(`<`, `Replay Slower`),
+			key.WithDisabled(),
+		),
+		Forward: key.NewBinding(
+			key.WithKeys(`right`),
+			key.WithHelp(`→`, `Skip a Minute`),
+			key.WithDisabled(),
+		),
+		Back: key.NewBinding(
+			key.WithKeys(`left`),
+			key.WithHelp(`←`, `Back a Minute`),
+			key.WithDisabled(),
+		),
+		Lifetime: key.NewBinding(
+			key.WithKeys(`l`),
+			key.WithHelp(`l`, `Toggle Lifetime Stats`),
+		),
+		Chart: key.NewBinding(
+			key.WithKeys(`c`),
+			key.WithHelp(`c`, `Latency/Jitter Chart`),
+		),
+	}
+}
+
 func (k keyMap) ShortHelp() []key.Binding {
-	return []key.Binding{k.Help, k.Quit}
+	return []key.Binding{k.Help, k.Incidents, k.Quit}
 }
 
 func (k keyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
 		{k.Fast, k.Slow},
-		{k.Debug},
-		{k.Help, k.Quit},
+		{k.Debug, k.Focus, k.Lifetime},
+		{k.Help, k.Incidents, k.Chart, k.Quit},
 		{k.Warn, k.ClearWarn},
 		{k.Fail, k.ClearFail},
+		{k.Play, k.Speed, k.Slowdown},
+		{k.Forward, k.Back},
 	}
 }
 
 type model struct {
-	keys     keyMap          // key bindings
-	help     help.Model      // help indicators
-	ping     *probing.Pinger // actual thing doing the pinging
-	spin     spinner.Model   // indicator to ensure we're still alive
-	data     []pingPoint     // stream of fired and potentially received packets
-	quitting bool            // TODO: rename `quit` (why not have all state be 4 chars long?)
-	w, h     int             // world size
+	engine // everything being monitored (and what's happened to it)
+
+	keys     keyMap        // key bindings
+	help     help.Model    // help indicators
+	focus    int           // index into `targets` of the one driving the statistics and histogram
+	spin     spinner.Model // indicator to ensure we're still alive
+	quitting bool          // TODO: rename `quit` (why not have all state be 4 chars long?)
+	draining bool          // quitting once the probes in flight come back (or are given up on)
+	w, h     int           // world size
 
 	speedX  int  // index into `intervals` slice
 	changed bool // have we slowed down since starting (we start fast to fill the screen, but slow to a reasonable interval)
 
-	warn  uint // high latency warning semaphore
-	debug bool
-}
+	column time.Duration // wall-clock time per chart column (0 = a probe per column, see --column)
+	replay *replay       // events come from a recording instead of probers (nil when live)
+	frames *json.Decoder // events come from a daemon instead of probers (nil when not attached)
+
+	debug bool // show the debug header
+	bell  bool // ring the terminal bell when an alert fires
+
+	incidents bool      // show the incident timeline instead of the chart
+	chart     chartMode // what the chart plots
+	lifetime  bool      // lifetime statistics drive the average and deviation lines (instead of the recent window)
 
-type pingPoint struct {
-	Rtt time.Duration
-	ID  int
-	Seq int
+	triage *hops  // which targets are the gateway, ISP and destination (nil when not triaging)
+	mtr    *route // targets are the hops along a path (nil when not tracing)
 }
 
 func (m model) Init() tea.Cmd {
-	return tea.Batch(
-		rescale(1),                        // start pinging
+	cmds := []tea.Cmd{
 		m.spin.Tick,                       // start spinner
 		tea.SetWindowTitle(`Checking...`), // get a fun window title going!
-	)
+	}
+	for i, s := range m.targets {
+		cmds = append(cmds, listen(i, s.ping.Events())) // start listening to the pingers
+	}
+	if m.replay != nil {
+		cmds = append(cmds, tickReplay())
+	}
+	if m.frames != nil {
+		cmds = append(cmds, receive(m.frames))
+	}
+	return tea.Batch(cmds...)
 }
 
 type wrappedMsg struct {
-	more chan tea.Msg
-	this tea.Msg
+	target int // index into `targets`
+	more   <-chan probe.Event
+	this   probe.Event
+}
+
+// listen waits for the next event from a prober (returning nothing once it has stopped)
+func listen(target int, events <-chan probe.Event) tea.Cmd {
+	return func() tea.Msg {
+		ev, ok := <-events
+		if !ok {
+			return nil
+		}
+		return wrappedMsg{
+			target: target,
+			more:   events,
+			this:   ev,
+		}
+	}
 }
 
 func printf(format string, args ...interface{}) tea.Cmd {
+	return printLine(fmt.Sprintf(format, args...))
+}
+
+// printLine prints a timestamped line above the chart (tests swap it out to see what would have been printed)
+var printLine = func(line string) tea.Cmd {
 	const timeFormat = `2006-01-02 15:04:05.000`
-	return tea.Printf(time.Now().Format(timeFormat)+`: `+format, args...)
+	return tea.Println(time.Now().Format(timeFormat) + `: ` + line)
 }
 
 // message to modify the interval of the pinger
@@ -182,70 +439,21 @@ var intervals = []time.Duration{
 	// time.Minute,
 }
 
-func (m *model) rescale(next time.Duration) tea.Cmd {
-	pinger := probing.New(m.ping.Addr())
-	pinger.Interval = next
-
-	// don't record RTTs (we're only interested in the last m.w points)
-	pinger.RecordRtts = false
-
-	// ensure we have a different ID when rescaling (to avoid sequence collisions)
-	for pinger.ID() == m.ping.ID() {
-		pinger.SetID(rand.IntN(math.MaxUint16))
-	}
-
-	m.ping.Stop()
-	m.ping = pinger
-
-	events := make(chan tea.Msg, 20)
-
-	pinger.OnSend = func(ping *probing.Packet) {
-		events <- ping
-	}
-	pinger.OnSendError = func(ping *probing.Packet, err error) {
-		events <- printf(`on-send-err: %#v; %s`, ping, err.Error())
-	}
-	pinger.OnRecv = func(ping *probing.Packet) {
-		events <- ping
-	}
-	pinger.OnRecvError = func(err error) {
-		// TODO: ignore i/o timeouts (it's a read loop)
-		if errors.Is(err, os.ErrDeadlineExceeded) {
-			return
-		}
-		events <- printf(`on-recv-err: %s`, err.Error())
-	}
-
-	return tea.Batch(
-		func() tea.Msg {
-			return wrappedMsg{
-				more: events,
-				this: <-events,
-			}
-		},
-		func() tea.Msg {
-			return m.ping.Run()
-		},
-	)
-}
-
 // message to check the status of a specific ping, if we can't see it, sound the alarm!!!
 type howAreYaNow struct {
-	// this is currently complicated because during re-scales, the pinger changes IDs and re-uses sequence numbers
-	ID  int
-	Seq int
+	Target int
+	Probe  probeID
 }
 
-// message to clear the warning semaphore
-type goodAndYou struct{}
-
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case rescaleMessage:
 		desired := min(max(int(msg), 0), len(intervals)-1)
 		if m.speedX != desired {
 			m.speedX = desired
-			return m, m.rescale(intervals[desired])
+			for _, s := range m.targets {
+				s.ping.SetInterval(intervals[desired])
+			}
 		}
 
 	case tea.KeyMsg:
@@ -259,40 +467,76 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case key.Matches(msg, m.keys.Help):
 			m.help.ShowAll = !m.help.ShowAll
 		case key.Matches(msg, m.keys.Quit):
-			m.quitting = true // TODO: print final statistics on quitting
-			m.ping.Stop()
-			// TODO: wait for a window for any outstanding pings
-			return m, tea.Quit
+			if m.draining || m.replay != nil || m.frames != nil || m.inFlight() == 0 {
+				return m.quit() // nothing to wait for (or no patience for it)
+			}
+			m.draining = true
+			for _, s := range m.targets {
+				s.ping.Halt() // nothing new to wait on, only the probes already out
+			}
+			return m, tea.Tick(drainTimeout, func(time.Time) tea.Msg { return drainedMsg{} })
+		case key.Matches(msg, m.keys.Incidents):
+			m.incidents = !m.incidents
+		case key.Matches(msg, m.keys.Chart):
+			m.chart = m.chart.next()
 		case key.Matches(msg, m.keys.Debug):
 			m.debug = !m.debug
+		case key.Matches(msg, m.keys.Play):
+			m.replay.paused = !m.replay.paused
+		case key.Matches(msg, m.keys.Speed):
+			m.replay.speed = min(m.replay.speed*2, 1024)
+		case key.Matches(msg, m.keys.Slowdown):
+			m.replay.speed = max(m.replay.speed/2, 0.25)
+		case key.Matches(msg, m.keys.Forward):
+			return m.seek(m.replay.now.Add(seekStep))
+		case key.Matches(msg, m.keys.Back):
+			return m.seek(m.replay.now.Add(-seekStep))
+		case key.Matches(msg, m.keys.Lifetime):
+			m.lifetime = !m.lifetime
+		case key.Matches(msg, m.keys.Focus):
+			m.focus = (m.focus + 1) % m.visible()
 		case key.Matches(msg, m.keys.Warn):
-			for i := range m.data {
-				m.data[i].Rtt += 50 * time.Millisecond
+			s := &m.targets[m.focus]
+			for i := range s.data {
+				s.data[i].Rtt += 50 * time.Millisecond
 			}
+			return m, m.notify(m.focus, m.force(m.focus, alert.Warning, m.now()))
 		case key.Matches(msg, m.keys.Fail):
-			if m.warn == 0 {
-				m.warn++
-			}
+			return m, m.notify(m.focus, m.force(m.focus, alert.Critical, m.now()))
 		case key.Matches(msg, m.keys.ClearWarn):
-			for i := range m.data {
-				m.data[i].Rtt -= 50 * time.Millisecond
+			s := &m.targets[m.focus]
+			for i := range s.data {
+				s.data[i].Rtt -= 50 * time.Millisecond
 			}
+			return m, m.notify(m.focus, m.force(m.focus, alert.None, m.now()))
 		case key.Matches(msg, m.keys.ClearFail):
-			if m.warn > 0 {
-				m.warn--
-			}
+			return m, m.notify(m.focus, m.force(m.focus, alert.None, m.now()))
 		default:
 			return m, printf(`unknown key: %v`, msg)
 		}
 
 	case wrappedMsg:
-		m, cmd := m.Update(msg.this)
-		return m, tea.Batch(cmd, func() tea.Msg {
-			return wrappedMsg{
-				more: msg.more,
-				this: <-msg.more,
-			}
-		})
+		m, cmd := m.event(msg.target, msg.this)
+		m, done := m.drained()
+		return m, tea.Batch(cmd, listen(msg.target, msg.more), done)
+
+	case drainedMsg:
+		return m.quit()
+
+	case attachMsg:
+		m, cmd := m.follow(msg.frame)
+		return m, tea.Batch(cmd, receive(msg.dec))
+
+	case detachedMsg:
+		return m, printf(`%s`, detached(msg.err))
+
+	case replayTick:
+		if m.replay.paused {
+			return m, tickReplay()
+		}
+		step := time.Duration(float64(replayFrame) * m.replay.speed)
+		m, cmd := m.seek(m.replay.now.Add(step))
+		return m, tea.Batch(cmd, tickReplay())
 
 	case spinner.TickMsg:
 		var cmd tea.Cmd
@@ -303,81 +547,69 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.w, m.h = msg.Width, msg.Height
 		m.help.Width = msg.Width
 
-	case *probing.Packet:
-		if msg.Rtt == 0 {
-			m.data = append(m.data, pingPoint{
-				ID:  msg.ID,
-				Seq: msg.Seq,
-			})
-			if m.w > 0 && len(m.data) > m.w {
-				m.data = m.data[len(m.data)-m.w:]
-
-				// once we fill the width... let's rescale to a more reasonable interval
-				if !m.changed {
-					m.changed = true
-					return m, rescale(len(intervals) - 2) // not a snail, but not a rabbit
-				}
-			}
-			// return m, printf("send: id: %d; seq: %d", msg.ID, msg.Seq)
-			return m, func() tea.Msg {
-				time.Sleep(time.Second)
-				return howAreYaNow{ID: msg.ID, Seq: msg.Seq}
-			}
+	case howAreYaNow:
+		_, changes, note := m.missing(msg.Target, msg.Probe, m.now())
+		m, done := m.drained()
+		if note != `` {
+			return m, tea.Batch(printf(`%s`, note), m.notify(msg.Target, changes), done)
 		}
+		return m, tea.Batch(m.notify(msg.Target, changes), done)
 
-		// TODO: use slices.BinarySearchFunc to find the right index
-		myIndex := -1
-		// NOTE: going backwards as sequence values are re-used when rescaling the pinger
-		// This'll ensure previous values aren't updated, only the newest sequence value
-		// TODO: this is fragile AF! (but it works for now)
-		for i := len(m.data) - 1; i >= 0; i-- {
-			p := m.data[i]
-			if p.Seq == msg.Seq {
-				myIndex = i
-				break
-			}
-		}
-		if myIndex < 0 {
-			return m, printf("recv: id: %d; seq: %d; not found", msg.ID, msg.Seq)
+	default:
+		if _, allowed := allowedMessages[fmt.Sprintf(`%T`, msg)]; !allowed {
+			return m, printf(`unhandled message: %T(%#v)`, msg, msg)
 		}
+	}
+	return m, nil
+}
 
-		m.data[myIndex].Rtt = msg.Rtt
-		return m, nil // printf("recv: id: %d; seq: %d", msg.ID, msg.Seq)
+// event records what happened to a probe of a target
+func (m model) event(target int, ev probe.Event) (model, tea.Cmd) {
+	if m.draining && (ev.Kind == probe.Sent || !m.targets[target].flying.awaited(flightKey{ev.ID, ev.Seq})) {
+		return m, nil // only the probes in flight when quitting still count
+	}
+	changes, note := m.observe(target, ev)
+	cmds := []tea.Cmd{m.notify(target, changes)}
+	if note != `` {
+		cmds = append(cmds, printf(`%s`, note))
+	}
 
-	case howAreYaNow:
-		myPrecious := -1
-		for i, p := range m.data {
-			if p.ID == msg.ID && p.Seq == msg.Seq {
-				myPrecious = i
-				break
+	switch ev.Kind {
+	case probe.Sent:
+		s := &m.targets[target]
+		if drop := m.overflow(s.data, ev.Time); drop > 0 {
+			s.data = s.data[drop:]
+
+			// once we fill the width... let's rescale to a more reasonable interval
+			if !m.changed {
+				m.changed = true
+				cmds = append(cmds, rescale(len(intervals)-2)) // not a snail, but not a rabbit
 			}
 		}
-		if myPrecious < 0 {
-			return m, printf("how-are-ya-now: id: %d; seq: %d; not found", msg.ID, msg.Seq)
-		}
-		if m.data[myPrecious].Rtt != 0 {
-			return m, nil // all good, we've received the packed
-		}
+		cmds = append(cmds, m.after(time.Second, howAreYaNow{Target: target, Probe: probeID{ID: ev.ID, Seq: ev.Seq, Sent: ev.Time}}))
 
-		m.warn++
-		return m, func() tea.Msg {
-			time.Sleep(20 * time.Second)
-			return goodAndYou{}
+	case probe.Received:
+		if m.mtr != nil && ev.From == m.mtr.dest {
+			m = m.reached(target)
 		}
+	}
+	return m, tea.Batch(cmds...)
+}
 
-	case goodAndYou:
-		m.warn--
-
-	case tea.Cmd:
-		// hacky work-around to get pinger to send commands to the model
-		return m, msg
-
-	default:
-		if _, allowed := allowedMessages[fmt.Sprintf(`%T`, msg)]; !allowed {
-			return m, printf(`unhandled message: %T(%#v)`, msg, msg)
-		}
+// overflow is how many of the oldest data points have scrolled off the screen
+func (m model) overflow(data []pingPoint, now time.Time) int {
+	if m.w == 0 {
+		return 0
 	}
-	return m, nil
+	if m.column <= 0 {
+		return max(len(data)-m.w, 0)
+	}
+	oldest := now.Add(-time.Duration(m.w) * m.column)
+	drop := 0
+	for drop < len(data) && data[drop].Sent.Before(oldest) {
+		drop++
+	}
+	return drop
 }
 
 var allowedMessages = map[string]struct{}{
@@ -388,17 +620,16 @@ var allowedMessages = map[string]struct{}{
 
 func (m model) View() string {
 	if m.quitting {
-		return `Bye-bye` + "\n" // newline needed to not replace content on final terminal
+		return `` // main prints the summary once the program is done
 	}
 
-	// TODO: width is based on the number of characters in the axis title
-	// 23.3  = 4 characters
-	// 192   = 3 characters
-	// 12345 = 5 characters
-	// [space][number][space][y-axis] - typically seeing 4 character numbers, making buffer 6
-	// y-axis itself counts as the 1st data-point (cause it's drawn with ┤ and ┼ characters)
-	const buffer = 3 /* precision */ + 1 /* padding */ + 2 /* axis */ + 10 /* histogram */ + 2 /* border */
-	maxPoints := m.w - buffer
+	const buffer = chart.Margin /* padding, y-axis labels and axis */ + 10 /* histogram */ + 15 /* percentiles */ + 2 /* border */
+	// the chart needs at least a column (even before the first resize)
+	maxPoints := max(m.w-buffer, 1)
+	if m.w > 0 && m.w <= buffer {
+		line := fmt.Sprintf(`Terminal too small: %d columns wide, monet needs more than %d`, m.w, buffer)
+		return lipgloss.Place(m.w, m.h, lipgloss.Center, lipgloss.Center, lipgloss.NewStyle().Width(m.w).Render(line))
+	}
 
 	var head string
 	if m.debug {
@@ -406,38 +637,23 @@ func (m model) View() string {
 		head = lipgloss.Place(m.w, 1, lipgloss.Center, lipgloss.Center, line)
 	}
 
-	if len(m.data) < 1 || m.w == 0 {
+	if m.w == 0 || !slices.ContainsFunc(m.targets, func(s series) bool { return len(s.data) > 0 }) {
 		return head
 	}
 
-	stream := m.data
-
-	if len(stream) > maxPoints {
-		stream = stream[len(stream)-maxPoints:]
-	}
-
-	points := make([]float64, len(stream))
-	for i, d := range stream {
-		if d.Rtt == 0 {
-			points[i] = math.NaN()
-			continue
-		}
-		v := dur2ms(d.Rtt)
-		// keep data in an interesting range (TODO: make this configurable + smarter)
-		points[i] = min(max(v, 0), 95)
-		if v != points[i] {
-			// TODO: signify truncated value
-		}
-	}
+	// the focused target drives the statistics, deviation lines and histogram (the others are just along for the ride)
+	focus := m.targets[m.focus]
+	now := m.now()
+	cols := focus.columns(maxPoints, m.column, now)
+	points := chartPoints(cols, m.chart.point())
 
 	// // perform non-pro-bing statistics
 	// // TODO: keep this math as time.Duration once we don't care about comparing to ^^ (the pro-bing stats)
 	// sd := dur2ms(time.Duration(math.Sqrt(float64(m.dem2 / time.Duration(m.recv)))))
 	// avg := dur2ms(m.mean)
-	stats := m.ping.Statistics()
-	sd := dur2ms(stats.StdDevRtt)
-	avg := dur2ms(stats.AvgRtt)
-	recv := stats.PacketsRecv
+	mean, stdDev, recv := focus.stats(m.lifetime)
+	sd := dur2ms(stdDev)
+	avg := dur2ms(mean)
 	sd1 := sd*1 + avg
 	sd2 := sd*2 + avg
 	sd3 := sd*3 + avg
@@ -445,63 +661,110 @@ func (m model) View() string {
 		line := fmt.Sprintf(`recv: %6d, avg: %.3fms, sd: %.3fms, 1sd: %.3fms, 2sd: %.3fms, 3sd: %.3fms`, recv, avg, sd, sd1, sd2, sd3)
 		head += "\n" + lipgloss.Place(m.w, 1, lipgloss.Center, lipgloss.Center, line)
 	}
+	if r, ok := focus.ping.(probe.Reporter); ok && m.debug {
+		head += "\n" + lipgloss.Place(m.w, 1, lipgloss.Center, lipgloss.Center, r.Report())
+	}
+	if m.debug && len(focus.phases) > 0 {
+		parts := make([]string, len(focus.phases))
+		for i, p := range focus.phases {
+			parts[i] = fmt.Sprintf(`%s: %.3fms`, p.Name, dur2ms(p.Dur))
+		}
+		line := `last: ` + strings.Join(parts, `, `)
+		head += "\n" + lipgloss.Place(m.w, 1, lipgloss.Center, lipgloss.Center, line)
+	}
 
-	// remove NaNs from the data (duplicate points slice as delete func modifies the slice)
-	nanLessPoints := slices.DeleteFunc(slices.Clone(points), math.IsNaN)
-	if len(nanLessPoints) == 0 {
-		return head + "\n" + m.help.View(m.keys)
-	}
-
-	minimum := math.Floor(min(slices.Min(nanLessPoints), avg))
-	maximum := math.Ceil(max(slices.Max(nanLessPoints), sd3))
-	// TODO: really figure out the y-axis labels.  Currently, their width can change based on the data: 0.0, 10.0, 100.0 (all have different column widths)
-	chart := asciigraph.PlotMany(
-		[][]float64{
-			slices.Repeat([]float64{avg}, maxPoints),
-			slices.Repeat([]float64{sd1}, maxPoints),
-			slices.Repeat([]float64{sd2}, maxPoints),
-			slices.Repeat([]float64{sd3}, maxPoints),
-			points,
-		},
-		asciigraph.Precision(1), // decimals
-		// asciigraph.Width(m.w-buffer), // chart area (not counting labels, axis, etc) // NOTE: controlled by maxPoints instead
-		asciigraph.Height(20), //m.h-4-6 /* caption */), // -4 for spinner, title padding, and something else
-		asciigraph.SeriesColors(
-			asciigraph.Green,
-			asciigraph.Yellow,
-			asciigraph.Orange,
-			asciigraph.Red,
-			asciigraph.Blue,
-		),
-		asciigraph.SeriesLegends(
-			"average",
-			"1 deviation",
-			"2 deviations",
-			"3 deviations",
-			m.ping.Addr(),
-		),
-		asciigraph.Caption(m.spin.View()+" Ping every "+m.ping.Interval.String()),
+	// one line per target (when there is more than one) so every target's statistics are visible
+	var summary string
+	if m.mtr != nil {
+		summary = "\n" + m.hopTable()
+	} else if len(m.targets) > 1 {
+		summary = "\n" + m.summary()
+	}
+	if m.mtr == nil {
+		head += "\n" + m.callQuality()
+	}
+	if m.triage != nil {
+		head += "\n" + m.verdict()
+	}
+	if m.replay != nil {
+		head += "\n" + m.replayStatus()
+	}
+	if m.draining {
+		line := fmt.Sprintf(`Waiting for %d probes still in flight (q again to stop waiting)`, m.inFlight())
+		head += "\n" + lipgloss.Place(m.w, 1, lipgloss.Center, lipgloss.Center, line)
+	}
+	if m.incidents {
+		return m.framed(head + "\n" + m.timeline(m.w-2, now) + summary + "\n" + m.help.View(m.keys))
+	}
+
+	lines := []chart.Line{
+		chart.Flat(avg, maxPoints, lipgloss.Color(`2`), `average`),
+		chart.Flat(sd1, maxPoints, lipgloss.Color(`11`), `1 deviation`),
+		chart.Flat(sd2, maxPoints, lipgloss.Color(`214`), `2 deviations`),
+		chart.Flat(sd3, maxPoints, lipgloss.Color(`9`), `3 deviations`),
+	}
+	var everything []float64 // every value on the chart (for the axis bounds)
+	// always on the axis, so the reference lines are too (as long as they fit, points above maxChartMs are clipped anyway)
+	floor, ceiling := min(avg, maxChartMs), min(sd3, maxChartMs)
+	sketch := focus.quant // percentiles beside the histogram
+	caption := "Ping every " + focus.ping.Interval().String() + ", lines from " + m.statsName()
+	if m.chart == jitterChart {
+		lines = jitterLines(cols)
+		everything = values(lines[0].Points)
+		floor, ceiling = 0, 0
+		caption = "Ping every " + focus.ping.Interval().String() + ", change in round trip between replies"
+		sketch, recv = focus.spread, focus.spread.Count
+	}
+	if odd := focus.flying.odd(); odd != `` {
+		caption += ", ◆ " + odd // see chart.Point.Odd
+	}
+	for i, s := range m.targets {
+		if i == m.focus {
+			continue // drawn last (on top of everything else)
+		}
+		if m.mtr != nil {
+			break // a line per hop is just noise, the table has them covered
+		}
+		p := chartPoints(s.columns(maxPoints, m.column, now), m.chart.point())
+		lines = append(lines, chart.Line{Points: p, Color: targetColors[i%len(targetColors)], Legend: s.label()})
+		everything = append(everything, values(p)...)
+	}
+	lines = append(lines, chart.Line{Points: points, Color: targetColors[m.focus%len(targetColors)], Legend: focus.label()})
+	nanLessPoints := values(points)
+	everything = append(everything, nanLessPoints...)
+	if len(everything) == 0 {
+		return head + summary + "\n" + m.help.View(m.keys)
+	}
 
-		// prevent axis from changing rapidly
-		// TODO: ensure there are HEIGHT unique axis values (with 2 decimal places)
-		asciigraph.LowerBound(minimum),
-		asciigraph.UpperBound(maximum),
-	)
+	// prevent axis from changing rapidly
+	minimum := math.Floor(min(slices.Min(everything), floor))
+	maximum := math.Ceil(max(slices.Max(everything), ceiling))
+	if maximum == minimum {
+		maximum++ // perfectly steady (whole number) replies, the histogram needs some range to divide up
+	}
+	plot := chart.Chart{
+		Lines:   lines,
+		Min:     minimum,
+		Max:     maximum,
+		Height:  20,
+		Caption: m.spin.View() + " " + caption,
+		Labels:  timeLabels(cols),
+		Marks:   marks(cols),
+	}.Render()
 
 	// histogram logic has a real bad day if interval is 0, which will require > 1 data point
 	if len(nanLessPoints) < 2 {
-		return head + "\n" + chart + "\n" + m.help.View(m.keys)
+		return head + "\n" + plot + summary + "\n" + m.help.View(m.keys)
 	}
 
 	// create a really rough histogram given the current data's range
 	{
 		interval := maximum - minimum
 		ratio := float64(20) / interval
-		min2 := math.Round(minimum * ratio) // not the same rounding algorithm as asciigraph
 
 		buckets := make([]int, 21)
 		for _, v := range nanLessPoints {
-			y := int(math.Round(v*ratio) - min2)
+			y := int(math.Round((v - minimum) * ratio)) // same rounding as the chart's rows
 			buckets[y]++
 		}
 
@@ -536,51 +799,160 @@ func (m model) View() string {
 		slices.Reverse(histogram) // bottom is the smaller number
 
 		// add total to bottom of histogram
-		histogram = append(histogram, ``, fmt.Sprintf(` %8d `, recv))
+		histogram = append(histogram, ``, ``, fmt.Sprintf(` %8d `, recv))
 
 		// sanity check
-		if len(histogram) != 23 {
+		if len(histogram) != 24 {
 			panic(fmt.Sprintf(`bad histogram: %d`, len(histogram)))
 		}
-		if strings.Count(chart, "\n") != 23 {
-			panic(fmt.Sprintf(`bad chart: %d`, strings.Count(chart, "\n")))
+		if strings.Count(plot, "\n") != 24 {
+			panic(fmt.Sprintf(`bad chart: %d`, strings.Count(plot, "\n")))
 		}
 
-		// prepend a histogram to the chart
-		chart = lipgloss.JoinHorizontal(lipgloss.Top, strings.Join(histogram, "\n"), chart)
+		// prepend a histogram (and the percentiles it doesn't show well) to the chart
+		plot = lipgloss.JoinHorizontal(lipgloss.Top, percentiles(sketch), strings.Join(histogram, "\n"), plot)
 	}
 
-	screen := head + "\n" + chart + "\n" + m.help.View(m.keys) // TODO: join vertical
+	return m.framed(head + "\n" + plot + summary + "\n" + m.help.View(m.keys)) // TODO: join vertical
+}
 
+// framed draws a border around the screen, colored by the most severe alert firing
+func (m model) framed(screen string) string {
 	var frame = lipgloss.NewStyle().
 		Border(lipgloss.HiddenBorder()).
 		Align(lipgloss.Left, lipgloss.Center).
 		Width(m.w - 2)
 
-	{
-		maximum := slices.Max(nanLessPoints)
-		if maximum > 90 {
-			frame = frame.BorderForeground(RED).
-				Foreground(RED).
-				Border(lipgloss.DoubleBorder())
-		} else if maximum > 50 {
-			frame = frame.BorderForeground(YELLOW).
-				Foreground(YELLOW).
-				Border(lipgloss.DoubleBorder())
-		}
+	// the alert rules pick the border (see --rules)
+	severity := alert.None
+	for _, s := range m.targets {
+		severity = max(severity, s.alerts.Severity())
 	}
-
-	if m.warn > 0 {
-		frame = frame.BorderForeground(RED).
+	if m.mtr != nil {
+		// routers along a path commonly skip replies (rate limits), so when tracing only the charted hop counts
+		severity = m.targets[m.focus].alerts.Severity()
+	}
+	if severity != alert.None {
+		frame = frame.BorderForeground(severityColors[severity]).
+			Foreground(severityColors[severity]).
 			Border(lipgloss.DoubleBorder())
 	}
 
 	return frame.Render(screen)
 }
 
+// quantiles shown beside the histogram (the tail is where the lag lives)
+var quantiles = []struct {
+	name string
+	q    float64
+}{
+	{`p50`, 0.50},
+	{`p90`, 0.90},
+	{`p95`, 0.95},
+	{`p99`, 0.99},
+	{`p99.5`, 0.995},
+	{`p99.9`, 0.999},
+	{`p99.95`, 0.9995},
+}
+
+// percentiles renders the session's percentiles (in ms) as a panel 15 characters wide
+func percentiles(s stats.Sketch) string {
+	lines := []string{``, `  percentiles  `, ``}
+	for _, q := range quantiles {
+		lines = append(lines, fmt.Sprintf(` %-6s%7.1f `, q.name, dur2ms(s.Quantile(q.q))))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// colors of each target's line on the chart (in the order they were given on the command line)
+var targetColors = []lipgloss.Color{
+	`12`, // blue
+	`13`, // magenta
+	`14`, // cyan
+	`5`,  // purple
+	`3`,  // olive
+	`6`,  // teal
+}
+
+// summary renders a line of statistics per target, marking the focused one and any in a warning state
+func (m model) summary() string {
+	pad := 0
+	for _, s := range m.targets {
+		pad = max(pad, len(s.label()))
+	}
+	lines := make([]string, len(m.targets))
+	for i, s := range m.targets {
+		marker := ` `
+		if i == m.focus {
+			marker = `▶`
+		}
+		mean, sd, recv := s.stats(m.lifetime)
+		line := fmt.Sprintf(`%s %-*s  avg: %.3fms, sd: %.3fms, recv: %d`, marker, pad, s.label(), dur2ms(mean), dur2ms(sd), recv)
+		if odd := s.flying.odd(); odd != `` {
+			line += fmt.Sprintf(` (%s)`, odd)
+		}
+		if rule, firing := s.alerts.Firing(); firing {
+			line = lipgloss.NewStyle().Foreground(severityColors[rule.Severity]).Render(fmt.Sprintf(`%s  (%s: %s)`, line, rule.Severity, rule.Raise))
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// every how many columns the x-axis is labeled
+const labelEvery = 20
+
+// timeLabels labels the x-axis with the time of every labelEvery columns (counting from the newest)
+func timeLabels(cols []column) []chart.Label {
+	var labels []chart.Label
+	for i := len(cols) - 10; i >= 0; i -= labelEvery {
+		if !cols[i].at.IsZero() {
+			labels = append(labels, chart.Label{Column: i, Text: cols[i].at.Format(time.TimeOnly)})
+		}
+	}
+	slices.Reverse(labels)
+	return labels
+}
+
+// marks are the columns where the interval changed
+func marks(cols []column) []int {
+	var marks []int
+	for i, c := range cols {
+		if c.mark {
+			marks = append(marks, i)
+		}
+	}
+	return marks
+}
+
+// statsName describes which statistics are driving the average and deviation lines
+func (m model) statsName() string {
+	if m.lifetime {
+		return `lifetime`
+	}
+	return recent.String()
+}
+
+// parseWindow reads a --window, either a number of pings (`1000`) or a duration (`5m`)
+func parseWindow(v string) (stats.Window, error) {
+	if n, err := strconv.Atoi(v); err == nil && n > 0 {
+		return stats.Window{Size: n}, nil
+	}
+	if d, err := time.ParseDuration(v); err == nil && d > 0 {
+		return stats.Window{Age: d}, nil
+	}
+	return stats.Window{}, fmt.Errorf(`expected a positive number of pings or a duration, got %q`, v)
+}
+
 const RED = lipgloss.Color(`#FF0000`)
 const YELLOW = lipgloss.Color(`#FFA500`)
 
+// what color an alert of each severity turns things
+var severityColors = map[alert.Severity]lipgloss.Color{
+	alert.Warning:  YELLOW,
+	alert.Critical: RED,
+}
+
 func dur2ms(d time.Duration) float64 {
 	return float64(d.Microseconds()) / 1000
 }
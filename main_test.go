@@ -0,0 +1,191 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bign8/monet/internal/alert"
+	"github.com/bign8/monet/internal/probe"
+	"github.com/bign8/monet/internal/stats"
+	"github.com/charmbracelet/bubbles/help"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// testModel is a model watching a single fake target that never sends anything on its own (tests feed it events)
+func testModel() model {
+	return model{
+		engine: engine{targets: []series{{ping: probe.NewFake(`a`, nil), recent: recent, alerts: alert.NewTracker(rules, slow)}}},
+		keys:   newKeyMap(),
+		help:   help.New(),
+		speedX: 1,
+	}
+}
+
+// update runs msgs through model.Update (ignoring the commands it returns)
+func update(t *testing.T, m model, msgs ...tea.Msg) model {
+	t.Helper()
+	for _, msg := range msgs {
+		next, _ := m.Update(msg)
+		m = next.(model)
+	}
+	return m
+}
+
+var epoch = time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+func sentMsg(seq int) tea.Msg {
+	return wrappedMsg{this: probe.Event{Kind: probe.Sent, ID: 1, Seq: seq, Time: epoch.Add(time.Duration(seq) * time.Second)}}
+}
+
+func recvMsg(seq int, rtt time.Duration) tea.Msg {
+	sent := epoch.Add(time.Duration(seq) * time.Second)
+	return wrappedMsg{this: probe.Event{Kind: probe.Received, ID: 1, Seq: seq, Time: sent.Add(rtt), Rtt: rtt, TTL: -1}}
+}
+
+func checkMsg(seq int) tea.Msg {
+	return howAreYaNow{Probe: probeID{ID: 1, Seq: seq, Sent: epoch.Add(time.Duration(seq) * time.Second)}}
+}
+
+func TestUpdateProbes(t *testing.T) {
+	tests := []struct {
+		name             string
+		msgs             []tea.Msg
+		sent, recv, lost int
+		pending          int
+	}{
+		{
+			name: `nothing`,
+		},
+		{
+			name:    `in flight`,
+			msgs:    []tea.Msg{sentMsg(0)},
+			sent:    1,
+			pending: 1,
+		},
+		{
+			name: `answered`,
+			msgs: []tea.Msg{sentMsg(0), recvMsg(0, 20*time.Millisecond), checkMsg(0)},
+			sent: 1,
+			recv: 1,
+		},
+		{
+			name: `lost`,
+			msgs: []tea.Msg{sentMsg(0), checkMsg(0)},
+			sent: 1,
+			lost: 1,
+		},
+		{
+			name: `answered out of order`,
+			msgs: []tea.Msg{sentMsg(0), sentMsg(1), recvMsg(1, 10*time.Millisecond), recvMsg(0, 1500*time.Millisecond)},
+			sent: 2,
+			recv: 2,
+		},
+		{
+			name: `late`,
+			msgs: []tea.Msg{sentMsg(0), checkMsg(0), recvMsg(0, 8*time.Second)},
+			sent: 1,
+			lost: 1,
+		},
+		{
+			name: `duplicated`,
+			msgs: []tea.Msg{sentMsg(0), recvMsg(0, 20*time.Millisecond), recvMsg(0, 22*time.Millisecond), checkMsg(0)},
+			sent: 1,
+			recv: 1,
+		},
+		{
+			name:    `some of each`,
+			msgs:    []tea.Msg{sentMsg(0), sentMsg(1), recvMsg(0, 30*time.Millisecond), sentMsg(2), checkMsg(0), checkMsg(1)},
+			sent:    3,
+			recv:    1,
+			lost:    1,
+			pending: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := update(t, testModel(), tt.msgs...)
+			s := m.targets[0]
+			if s.sent != tt.sent || s.stat.Count != tt.recv || s.lost != tt.lost || s.pending() != tt.pending {
+				t.Errorf(`sent/recv/lost/pending = %d/%d/%d/%d, want %d/%d/%d/%d`,
+					s.sent, s.stat.Count, s.lost, s.pending(), tt.sent, tt.recv, tt.lost, tt.pending)
+			}
+		})
+	}
+}
+
+func TestUpdateKeys(t *testing.T) {
+	key := func(r rune) tea.Msg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}} }
+	tests := []struct {
+		name  string
+		msgs  []tea.Msg
+		check func(model) bool
+	}{
+		{`help`, []tea.Msg{key('?')}, func(m model) bool { return m.help.ShowAll }},
+		{`help twice`, []tea.Msg{key('?'), key('?')}, func(m model) bool { return !m.help.ShowAll }},
+		{`debug`, []tea.Msg{key('d')}, func(m model) bool { return m.debug }},
+		{`incidents`, []tea.Msg{key('i')}, func(m model) bool { return m.incidents }},
+		{`jitter chart`, []tea.Msg{key('c')}, func(m model) bool { return m.chart == jitterChart }},
+		{`back to latency`, []tea.Msg{key('c'), key('c')}, func(m model) bool { return m.chart == latencyChart }},
+		{`lifetime`, []tea.Msg{key('l')}, func(m model) bool { return m.lifetime }},
+		{`warning`, []tea.Msg{key('w')}, func(m model) bool { return m.targets[0].alerts.Severity() == alert.Warning }},
+		{`warning cleared`, []tea.Msg{key('w'), key('W')}, func(m model) bool { return m.targets[0].alerts.Severity() == alert.None }},
+		{`quit`, []tea.Msg{key('q')}, func(m model) bool { return m.quitting }},
+		{`quit waits`, []tea.Msg{sentMsg(0), key('q')}, func(m model) bool { return m.draining && !m.quitting }},
+		{`quit waits for replies`, []tea.Msg{sentMsg(0), key('q'), recvMsg(0, time.Millisecond)}, func(m model) bool { return m.quitting }},
+		{`quit stops waiting`, []tea.Msg{sentMsg(0), key('q'), key('q')}, func(m model) bool { return m.quitting }},
+		{`resize`, []tea.Msg{tea.WindowSizeMsg{Width: 120, Height: 40}}, func(m model) bool { return m.w == 120 && m.h == 40 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if m := update(t, testModel(), tt.msgs...); !tt.check(m) {
+				t.Errorf(`unexpected model after %v`, tt.msgs)
+			}
+		})
+	}
+}
+
+func TestView(t *testing.T) {
+	msgs := []tea.Msg{tea.WindowSizeMsg{Width: 160, Height: 45}}
+	for seq := range 30 {
+		msgs = append(msgs, sentMsg(seq), recvMsg(seq, time.Duration(10+seq%7)*time.Millisecond))
+	}
+	msgs = append(msgs, sentMsg(30), checkMsg(30))
+	m := update(t, testModel(), msgs...)
+	for _, mode := range []chartMode{latencyChart, jitterChart} {
+		m.chart = mode
+		if m.View() == `` {
+			t.Errorf(`chart mode %d: empty view`, mode)
+		}
+	}
+
+	for _, w := range []int{20, 30, 36} {
+		m := update(t, m, tea.WindowSizeMsg{Width: w, Height: 10})
+		if small := strings.Contains(m.View(), `too small`); small != (w < 36) {
+			t.Errorf(`%d columns: too small = %t`, w, small)
+		}
+	}
+}
+
+func TestParseWindow(t *testing.T) {
+	tests := []struct {
+		flag string
+		want stats.Window
+		ok   bool
+	}{
+		{`100`, stats.Window{Size: 100}, true},
+		{`30s`, stats.Window{Age: 30 * time.Second}, true},
+		{`1h30m`, stats.Window{Age: 90 * time.Minute}, true},
+		{`0`, stats.Window{}, false},
+		{`-5`, stats.Window{}, false},
+		{`-5s`, stats.Window{}, false},
+		{`30`, stats.Window{Size: 30}, true}, // a count, not seconds
+		{`lots`, stats.Window{}, false},
+	}
+	for _, tt := range tests {
+		got, err := parseWindow(tt.flag)
+		if (err == nil) != tt.ok || got.Size != tt.want.Size || got.Age != tt.want.Age {
+			t.Errorf(`-window %s: %s, %v, want %s, ok = %t`, tt.flag, got, err, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/bign8/monet/internal/alert"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// route is the path being traced by `monet mtr`, targets[i] is probing the hop i+1 routers away
+type route struct {
+	dest string // address the target answers from
+	end  int    // index into `targets` of the first hop to reach dest (-1 until found)
+}
+
+// furthest a path is traced (same as traceroute)
+const mtrHops = 30
+
+// mtrTargets creates a TTL-limited prober per hop on the way to target: `monet mtr [target]`
+func mtrTargets(target string) ([]series, *route) {
+	u, err := url.Parse(target)
+	if err != nil || !strings.Contains(target, `://`) {
+		u = &url.URL{Scheme: `icmp`, Host: target}
+	}
+
+	dest := u.Host
+	switch u.Scheme {
+	case `icmp`:
+		// replies are matched on the address they come from, so settle on one up front
+		addr, err := net.ResolveIPAddr(`ip`, u.Hostname())
+		chk(`Error resolving target`, err)
+		dest = addr.IP.String()
+		u.Host = dest
+		if addr.IP.To4() == nil {
+			u.Host = `[` + dest + `]`
+		}
+	case `fake`:
+	default:
+		chk(`Error tracing route`, fmt.Errorf(`only icmp:// and fake:// targets can be traced, got %q`, u.Scheme))
+	}
+
+	all := make([]series, mtrHops)
+	for i := range all {
+		q := u.Query()
+		q.Set(`ttl`, strconv.Itoa(i+1))
+		u.RawQuery = q.Encode()
+		all[i] = newSeries(fmt.Sprintf(`hop %d`, i+1), u.String())
+		all[i].ping.SetInterval(intervals[len(intervals)-1])
+	}
+	return all, &route{dest: dest, end: -1}
+}
+
+// reached records that hop target made it to the destination, hops beyond it are just echoing the same answer
+func (m model) reached(target int) model {
+	if m.mtr.end >= 0 && m.mtr.end <= target {
+		return m
+	}
+	for i := target + 1; i < len(m.targets); i++ {
+		m.targets[i].ping.Stop()
+	}
+	if m.mtr.end < 0 || m.focus > target {
+		m.focus = target // the destination is what's interesting
+	}
+	m.mtr = &route{dest: m.mtr.dest, end: target}
+	return m
+}
+
+// visible is how many targets are on screen (hops past the destination aren't)
+func (m model) visible() int {
+	if m.mtr != nil && m.mtr.end >= 0 {
+		return m.mtr.end + 1
+	}
+	return len(m.targets)
+}
+
+// hopTable renders mtr's per hop statistics (the last hop that answered decides how long the table is until the destination is found)
+func (m model) hopTable() string {
+	rows := m.visible()
+	if m.mtr.end < 0 {
+		rows = 1
+		for i, s := range m.targets {
+			if s.stat.Count > 0 {
+				rows = i + 1
+			}
+		}
+	}
+
+	pad := len(`Host`)
+	for _, s := range m.targets[:rows] {
+		pad = max(pad, len(s.from))
+	}
+	lines := []string{
+		fmt.Sprintf(`      %-*s  %6s %5s %7s %7s %7s %7s`, pad, `Host`, `Loss%`, `Snt`, `Last`, `Avg`, `Wrst`, `StDev`),
+	}
+	for i, s := range m.targets[:rows] {
+		marker := ` `
+		if i == m.focus {
+			marker = `▶`
+		}
+		host := s.from
+		if host == `` {
+			host = `???`
+		}
+		loss := 0.0
+		if s.sent > 0 {
+			loss = float64(s.lost) / float64(s.sent) * 100
+		}
+		line := fmt.Sprintf(`%s %3d. %-*s  %5.1f%% %5d %7.1f %7.1f %7.1f %7.1f`, marker, i+1, pad, host, loss, s.sent,
+			dur2ms(s.last), dur2ms(s.stat.Mean()), dur2ms(s.worst), dur2ms(s.stat.StdDev()))
+		if severity := s.alerts.Severity(); severity != alert.None {
+			line = lipgloss.NewStyle().Foreground(severityColors[severity]).Render(line)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
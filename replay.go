@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/bign8/monet/internal/alert"
+	"github.com/bign8/monet/internal/probe"
+	"github.com/bign8/monet/internal/record"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// replay plays recorded sessions back through Update: `monet replay session.jsonl [more.jsonl...]`
+//
+// Time is virtual, the timers that spot missing replies (see after) fire on the recording's clock rather than the wall clock,
+// so a replay draws exactly the same thing every time no matter how fast it's played or where it's seeked to.
+type replay struct {
+	events     []record.Event
+	next       int            // index of the next event to play
+	target     map[string]int // index into `targets` of each recorded target
+	start, end time.Time      // beginning and end of the recording
+	now        time.Time      // how far into the recording we are
+	lastSent   map[int]time.Time
+
+	speed  float64 // multiple of real time
+	paused bool
+	timers []timer // messages due on the recording's clock (sorted by due)
+}
+
+type timer struct {
+	due time.Time
+	msg tea.Msg
+}
+
+// message to move the replay along
+type replayTick struct{}
+
+// how often the replay moves along (in real time)
+const replayFrame = 50 * time.Millisecond
+
+// how far the seek keys jump (in recorded time)
+const seekStep = time.Minute
+
+func tickReplay() tea.Cmd {
+	return tea.Tick(replayFrame, func(time.Time) tea.Msg { return replayTick{} })
+}
+
+// replayTargets loads recordings and creates a (never started) series per recorded target
+func replayTargets(paths []string) ([]series, *replay) {
+	if len(paths) == 0 {
+		chk(`Error replaying`, fmt.Errorf(`which recording? monet replay session.jsonl`))
+	}
+	r := &replay{target: map[string]int{}, lastSent: map[int]time.Time{}, speed: 1}
+	for _, path := range paths {
+		events, err := record.Read(path)
+		chk(`Error reading recording`, err)
+		r.events = append(r.events, events...)
+	}
+	if len(r.events) == 0 {
+		chk(`Error replaying`, fmt.Errorf(`nothing recorded in %v`, paths))
+	}
+	// rotated recordings may be given in any order
+	slices.SortStableFunc(r.events, func(a, b record.Event) int { return a.Time.Compare(b.Time) })
+
+	var all []series
+	for _, e := range r.events {
+		if _, ok := r.target[e.Target]; !ok {
+			r.target[e.Target] = len(all)
+			all = append(all, series{ping: probe.NewFake(e.Target, nil), recent: recent, alerts: alert.NewTracker(rules, slow)})
+		}
+	}
+	// keep going a little past the last event, so its probes have a chance to be noticed missing
+	r.start, r.end = r.events[0].Time, r.events[len(r.events)-1].Time.Add(time.Second)
+	r.now = r.start
+	return all, r
+}
+
+// after delivers msg once d has passed, on the wall clock or (when replaying) the recording's clock.
+// Attached terminals don't keep time at all, the daemon says when probes go missing.
+func (m model) after(d time.Duration, msg tea.Msg) tea.Cmd {
+	if m.frames != nil {
+		return nil
+	}
+	if r := m.replay; r != nil {
+		t := timer{due: r.now.Add(d), msg: msg}
+		i, _ := slices.BinarySearchFunc(r.timers, t.due, func(t timer, due time.Time) int {
+			if t.due.After(due) {
+				return 1
+			}
+			return -1 // after any timers due at the same time
+		})
+		r.timers = slices.Insert(r.timers, i, t)
+		return nil
+	}
+	return func() tea.Msg {
+		time.Sleep(d)
+		return msg
+	}
+}
+
+// now is the current time, on the wall clock or (when replaying) the recording's clock
+func (m model) now() time.Time {
+	if m.replay != nil {
+		return m.replay.now
+	}
+	return time.Now()
+}
+
+// advance plays everything recorded (and every timer due) up until the given time
+func (m model) advance(to time.Time) (model, tea.Cmd) {
+	r := m.replay
+	var cmds []tea.Cmd
+	for {
+		event := r.next < len(r.events) && !r.events[r.next].Time.After(to)
+		due := len(r.timers) > 0 && !r.timers[0].due.After(to)
+		var cmd tea.Cmd
+		switch {
+		case due && (!event || r.timers[0].due.Before(r.events[r.next].Time)):
+			t := r.timers[0]
+			r.timers = r.timers[1:]
+			r.now = t.due
+			var next tea.Model
+			next, cmd = m.Update(t.msg)
+			m = next.(model)
+		case event:
+			e := r.events[r.next]
+			r.next++
+			r.now = e.Time
+			target := r.target[e.Target]
+			ev := e.Probe()
+			if ev.Kind == probe.Sent {
+				m.guessInterval(target, ev.Time)
+			}
+			m, cmd = m.event(target, ev)
+		default:
+			r.now = to
+			return m, tea.Batch(cmds...)
+		}
+		cmds = append(cmds, cmd)
+	}
+}
+
+// guessInterval works out how often a recorded target was being probed (the recording doesn't say)
+func (m model) guessInterval(target int, sent time.Time) {
+	r := m.replay
+	if last, ok := r.lastSent[target]; ok {
+		gap := sent.Sub(last)
+		closest := slices.MinFunc(intervals, func(a, b time.Duration) int {
+			return int((a - gap).Abs() - (b - gap).Abs())
+		})
+		m.targets[target].ping.SetInterval(closest)
+	}
+	r.lastSent[target] = sent
+}
+
+// seek jumps to a point in the recording, going backwards means playing everything again from the start
+func (m model) seek(to time.Time) (model, tea.Cmd) {
+	r := m.replay
+	if to.Before(r.start) {
+		to = r.start
+	}
+	if to.After(r.end) {
+		to = r.end
+	}
+	if to.Before(r.now) {
+		for i, s := range m.targets {
+			m.targets[i] = series{name: s.name, ping: s.ping, recent: recent, alerts: alert.NewTracker(rules, slow)}
+		}
+		r.next, r.now, r.timers = 0, r.start, nil
+		clear(r.lastSent)
+	}
+	return m.advance(to)
+}
+
+// replayStatus shows where we are in the recording
+func (m model) replayStatus() string {
+	r := m.replay
+	state := `▶`
+	switch {
+	case r.next >= len(r.events):
+		state = `done`
+	case r.paused:
+		state = `paused`
+	}
+	done := 100.0
+	if total := r.end.Sub(r.start); total > 0 {
+		done = float64(r.now.Sub(r.start)) / float64(total) * 100
+	}
+	line := fmt.Sprintf(`replay %s %gx  %s / %s (%.0f%%)`, state, r.speed, r.now.Format(time.DateTime), r.end.Format(time.DateTime), done)
+	return lipgloss.Place(m.w, 1, lipgloss.Center, lipgloss.Center, lipgloss.NewStyle().Bold(true).Render(line))
+}
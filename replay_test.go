@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bign8/monet/internal/probe"
+	"github.com/bign8/monet/internal/record"
+)
+
+// recording writes two minutes of a target probed about every half a second (every tenth probe lost) and loads it for replay
+func recording(t *testing.T) model {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), `session.jsonl`)
+	w, err := record.Create(path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for seq := range 240 {
+		sent := epoch.Add(time.Duration(seq) * 510 * time.Millisecond)
+		events := []probe.Event{{Kind: probe.Sent, ID: 1, Seq: seq, Time: sent}}
+		if seq%10 != 9 {
+			rtt := time.Duration(20+seq%7) * time.Millisecond
+			events = append(events, probe.Event{Kind: probe.Received, ID: 1, Seq: seq, Time: sent.Add(rtt), Rtt: rtt, TTL: 57})
+		}
+		for _, ev := range events {
+			if err := w.Write(record.New(`fake://a`, ev)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	m := testModel()
+	m.targets, m.replay = replayTargets([]string{path})
+	m.changed = true
+	return m
+}
+
+// state is what a replay has made of the target so far
+func state(m model) string {
+	s := &m.targets[0]
+	return fmt.Sprintf(`sent %d, lost %d, pending %d, points %d, replies %d, mean %v, sd %v, p95 %v, interval %v`,
+		s.sent, s.lost, s.pending(), len(s.data), s.stat.Count, s.stat.Mean(), s.stat.StdDev(), s.quant.Quantile(.95), s.ping.Interval())
+}
+
+func TestReplaySeek(t *testing.T) {
+	m := recording(t)
+	r := m.replay
+	if len(m.targets) != 1 || m.targets[0].ping.Target() != `fake://a` {
+		t.Fatalf(`targets = %v, want just fake://a`, m.targets)
+	}
+
+	// played straight through
+	m, _ = m.seek(r.end)
+	end := state(m)
+	if s := m.targets[0]; s.sent != 240 || s.lost != 24 || s.stat.Count != 216 || s.ping.Interval() != 500*time.Millisecond {
+		t.Errorf(`played through: %s, want 240 sent, 24 lost, 216 replies, every 500ms`, end)
+	}
+
+	// played up to the middle
+	mid := r.start.Add(time.Minute)
+	m = recording(t)
+	r = m.replay
+	m, _ = m.seek(mid)
+	half := state(m)
+	if half == end {
+		t.Fatalf(`the middle looks like the end: %s`, half)
+	}
+
+	tests := []struct {
+		name  string
+		seeks []time.Time // carrying on from wherever the case before left off
+		want  string
+	}{
+		{name: `back to the middle`, seeks: []time.Time{r.end, mid}, want: half},
+		{name: `back to the start, then to the middle`, seeks: []time.Time{r.start, mid}, want: half},
+		{name: `forward to the end`, seeks: []time.Time{r.end}, want: end},
+		{name: `back and forward again`, seeks: []time.Time{r.start.Add(10 * time.Second), r.end}, want: end},
+		{name: `past either end`, seeks: []time.Time{r.start.Add(-time.Hour), r.end.Add(time.Hour)}, want: end},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, to := range tt.seeks {
+				m, _ = m.seek(to)
+			}
+			if got := state(m); got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"time"
+
+	"github.com/bign8/monet/internal/alert"
+	"github.com/bign8/monet/internal/chart"
+	"github.com/bign8/monet/internal/probe"
+	"github.com/bign8/monet/internal/quality"
+	"github.com/bign8/monet/internal/stats"
+	"github.com/bign8/monet/internal/triage"
+)
+
+// series is everything monet knows about a single target
+type series struct {
+	name   string         // label on the chart (defaults to the target)
+	ping   probe.Prober   // actual thing doing the pinging
+	data   []pingPoint    // stream of fired and potentially received packets
+	stat   stats.Online   // running statistics of every received packet
+	recent stats.Window   // statistics of only the recently received packets
+	quant  stats.Sketch   // percentiles of every received packet
+	spread stats.Sketch   // percentiles of the change in round trip between consecutive replies
+	alerts *alert.Tracker // which alert rules are firing
+	phases []probe.Phase  // breakdown of the latest round trip (for probers that provide one)
+	flying flights        // probes sent recently, to match replies to (and judge them by)
+
+	sent, lost  int           // lifetime counts (data only has what fits on screen)
+	last        time.Duration // latest round trip
+	best, worst time.Duration // fastest and slowest round trips
+	from        string        // who answered last (a router when TTL-limited)
+}
+
+type pingPoint struct {
+	Rtt  time.Duration
+	ID   int
+	Seq  int
+	Lost bool // no reply in time (see howAreYaNow)
+	Odd  bool // a reply came late, twice or out of order (see flights)
+
+	Sent     time.Time     // wall-clock time the probe went out
+	Recv     time.Time     // wall-clock time the reply came back (zero until it does)
+	Interval time.Duration // how often we were probing when this one was sent
+}
+
+// stats are the mean, standard deviation and count of either every or just the recently received packets
+func (s *series) stats(lifetime bool) (mean, sd time.Duration, count int) {
+	if lifetime {
+		return s.stat.Mean(), s.stat.StdDev(), s.stat.Count
+	}
+	return s.recent.Mean(), s.recent.StdDev(), s.recent.Count()
+}
+
+// restore catches up on probes that happened elsewhere (like in the daemon before attaching)
+func (s *series) restore(points []pingPoint) {
+	for _, p := range points {
+		s.data = append(s.data, p)
+		s.sent++
+		f := s.flying.sent(flightKey{p.ID, p.Seq}, p.Sent)
+		f.answered, f.lost = p.Rtt != 0, p.Lost
+		if f.answered && p.Sent.After(s.flying.newest) {
+			s.flying.newest = p.Sent
+		}
+		if p.Lost {
+			s.lost++
+			s.alerts.Add(alert.Sample{At: p.Sent, Lost: true})
+		}
+		if p.Rtt == 0 {
+			continue
+		}
+		if !p.Lost {
+			s.alerts.Add(alert.Sample{At: p.Sent, Rtt: p.Rtt})
+		}
+		s.stat.Add(p.Rtt)
+		s.recent.Add(p.Recv, p.Rtt)
+		s.quant.Add(p.Rtt)
+		if s.last != 0 {
+			s.spread.Add(quality.Change(s.last, p.Rtt))
+		}
+		s.last, s.best, s.worst = p.Rtt, fastest(s.best, p.Rtt), max(s.worst, p.Rtt)
+	}
+}
+
+// fastest is the quicker of two round trips (zero being none yet)
+func fastest(best, rtt time.Duration) time.Duration {
+	if best == 0 {
+		return rtt
+	}
+	return min(best, rtt)
+}
+
+// label is what the target is called on screen
+func (s *series) label() string {
+	if s.name != `` {
+		return s.name
+	}
+	return s.ping.Target()
+}
+
+// health summarizes (at most) the last n probes that have either been answered or given up on
+func (s *series) health(n int) triage.Health {
+	var h triage.Health
+	for i := len(s.data) - 1; i >= 0 && h.Answered+h.Lost < n; i-- {
+		switch {
+		case s.data[i].Rtt != 0:
+			h.Answered++
+		case s.data[i].Lost:
+			h.Lost++
+		}
+	}
+	return h
+}
+
+// pending is how many probes are still waiting on a reply (or to be given up on)
+func (s *series) pending() int {
+	n := 0
+	for _, p := range s.data {
+		if p.Rtt == 0 && !p.Lost {
+			n++
+		}
+	}
+	return n
+}
+
+// at finds the probe sent at a time in the data, returning -1 if it has scrolled out of the window
+func (s *series) at(sent time.Time) int {
+	i, found := slices.BinarySearchFunc(s.data, sent, func(p pingPoint, t time.Time) int { return p.Sent.Compare(t) })
+	if !found {
+		return -1
+	}
+	return i
+}
+
+// variation is, for every probe, how much its round trip changed since the reply sent before it and the RFC 3550
+// jitter as of it (-1 for either when there's nothing to compare yet, like for lost or pending probes)
+func (s *series) variation() (changes, jitter []time.Duration) {
+	changes, jitter = make([]time.Duration, len(s.data)), make([]time.Duration, len(s.data))
+	var before time.Duration
+	j := time.Duration(-1)
+	for i, p := range s.data {
+		changes[i], jitter[i] = -1, j
+		if p.Rtt == 0 || p.Lost {
+			continue
+		}
+		if before != 0 {
+			changes[i] = quality.Change(before, p.Rtt)
+			j = quality.Smooth(max(j, 0), changes[i])
+			jitter[i] = j
+		}
+		before = p.Rtt
+	}
+	return changes, jitter
+}
+
+// column is everything drawn in a single column of the chart
+type column struct {
+	at      time.Time       // when the column starts
+	probes  []pingPoint     // sent during the column
+	changes []time.Duration // per probe, the change in round trip since the previous reply (see variation)
+	jitter  time.Duration   // RFC 3550 jitter as of the column's last probe (-1 when there isn't any yet)
+	mark    bool            // the interval changed
+}
+
+// columns splits the data into (at most) n columns, a probe per column or (when width > 0) width of wall-clock time per column ending now
+func (s *series) columns(n int, width time.Duration, now time.Time) []column {
+	changed := func(i int) bool {
+		return i > 0 && s.data[i].Interval != s.data[i-1].Interval
+	}
+
+	changes, jitter := s.variation()
+	if width <= 0 {
+		offset := max(len(s.data)-n, 0)
+		cols := make([]column, len(s.data)-offset)
+		for i := range cols {
+			j := offset + i
+			cols[i] = column{at: s.data[j].Sent, probes: s.data[j : j+1], changes: changes[j : j+1], jitter: jitter[j], mark: changed(j)}
+		}
+		return cols
+	}
+
+	// line the columns up with the clock (rather than now) so they don't shimmer between frames
+	end := now.Truncate(width).Add(width)
+	start := end.Add(-time.Duration(n) * width)
+	cols := make([]column, n)
+	for i := range cols {
+		cols[i].at, cols[i].jitter = start.Add(time.Duration(i)*width), -1
+	}
+	for i, d := range s.data {
+		if d.Sent.Before(start) {
+			continue
+		}
+		c := &cols[min(int(d.Sent.Sub(start)/width), n-1)]
+		c.probes = append(c.probes, d)
+		c.changes = append(c.changes, changes[i])
+		c.jitter = jitter[i]
+		c.mark = c.mark || changed(i)
+	}
+	return cols
+}
+
+// point converts a column into a chart point: lost if any probe was lost, otherwise the slowest reply (or pending, or empty)
+func (c column) point() chart.Point {
+	p := chart.Point{Value: math.NaN(), Odd: c.odd()}
+	var worst time.Duration
+	for _, d := range c.probes {
+		switch {
+		case d.Lost:
+			return chart.Point{Value: math.NaN(), Lost: true, Odd: p.Odd}
+		case d.Rtt == 0:
+			p.Pending = true
+		default:
+			worst = max(worst, d.Rtt)
+		}
+	}
+	if worst == 0 {
+		return p
+	}
+	p = clip(dur2ms(worst))
+	p.Odd = c.odd()
+	return p
+}
+
+// odd is true when any reply in the column came late, twice or out of order
+func (c column) odd() bool {
+	return slices.ContainsFunc(c.probes, func(p pingPoint) bool { return p.Odd })
+}
+
+// changePoint converts a column into a chart point like point does, only with the biggest change in round trip instead of the slowest one
+func (c column) changePoint() chart.Point {
+	p := chart.Point{Value: math.NaN(), Odd: c.odd()}
+	biggest := time.Duration(-1)
+	for i, d := range c.probes {
+		switch {
+		case d.Lost:
+			return chart.Point{Value: math.NaN(), Lost: true, Odd: p.Odd}
+		case d.Rtt == 0:
+			p.Pending = true
+		default:
+			biggest = max(biggest, c.changes[i])
+		}
+	}
+	if biggest < 0 {
+		return p
+	}
+	p = clip(dur2ms(biggest))
+	p.Odd = c.odd()
+	return p
+}
+
+// jitterPoint converts a column into a chart point of its RFC 3550 jitter (empty until there is some)
+func (c column) jitterPoint() chart.Point {
+	if c.jitter < 0 {
+		return chart.Point{Value: math.NaN()}
+	}
+	return clip(dur2ms(c.jitter))
+}
+
+// clip keeps a value (in milliseconds) in an interesting range (TODO: make this configurable + smarter)
+func clip(v float64) chart.Point {
+	return chart.Point{Value: min(max(v, 0), maxChartMs), Clipped: v > maxChartMs}
+}
+
+// chartPoints converts columns into chart points with convert (like column.point)
+func chartPoints(cols []column, convert func(column) chart.Point) []chart.Point {
+	points := make([]chart.Point, len(cols))
+	for i, c := range cols {
+		points[i] = convert(c)
+	}
+	return points
+}
+
+// values are the (non-NaN) values of points
+func values(points []chart.Point) []float64 {
+	out := make([]float64, 0, len(points))
+	for _, p := range points {
+		if !math.IsNaN(p.Value) {
+			out = append(out, p.Value)
+		}
+	}
+	return out
+}
+
+// anything slower than this (in milliseconds) is drawn as an arrow at the top of the chart
+const maxChartMs = 95
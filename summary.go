@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// how long quitting waits on probes still in flight (they're given up on after a second anyway, this is just in case)
+const drainTimeout = 2 * time.Second
+
+// message sent once quitting has waited long enough for probes in flight
+type drainedMsg struct{}
+
+// inFlight is how many probes are still waiting on a reply (or to be given up on)
+func (m model) inFlight() int {
+	n := 0
+	for i := range m.targets {
+		n += m.targets[i].pending()
+	}
+	return n
+}
+
+// drained quits once nothing is in flight anymore (after the quit key started waiting)
+func (m model) drained() (model, tea.Cmd) {
+	if !m.draining || m.quitting || m.inFlight() > 0 {
+		return m, nil
+	}
+	return m.quit()
+}
+
+// quit stops probing and ends the program (main prints the summary)
+func (m model) quit() (model, tea.Cmd) {
+	m.quitting = true
+	for _, s := range m.targets {
+		s.ping.Stop()
+	}
+	return m, tea.Quit
+}
+
+// summary is what happened during a session, printed on quitting
+type summary struct {
+	Start    time.Time       `json:"start"`
+	End      time.Time       `json:"end"`
+	Duration float64         `json:"duration_s"`
+	Targets  []targetSummary `json:"targets"`
+}
+
+type targetSummary struct {
+	Name        string             `json:"name,omitempty"`
+	Target      string             `json:"target"`
+	Sent        int                `json:"sent"`
+	Received    int                `json:"received"`
+	Lost        int                `json:"lost"`
+	Loss        float64            `json:"loss"` // lost over sent (0-1)
+	Reordered   int                `json:"reordered"`
+	Late        int                `json:"late"`
+	Duplicates  int                `json:"duplicates"`
+	Odd         string             `json:"-"` // the three above for people (see flights.odd)
+	Min         float64            `json:"min_ms"`
+	Avg         float64            `json:"avg_ms"`
+	Max         float64            `json:"max_ms"`
+	StdDev      float64            `json:"stddev_ms"`
+	Percentiles map[string]float64 `json:"percentiles_ms"`
+	Incidents   []incidentSummary  `json:"incidents"`
+}
+
+type incidentSummary struct {
+	Start    time.Time  `json:"start"`
+	End      *time.Time `json:"end,omitempty"` // nil when it was still going on
+	Duration float64    `json:"duration_s"`
+	Probes   int        `json:"probes"`
+	Lost     int        `json:"lost"`
+	Worst    float64    `json:"worst_ms"`
+}
+
+// report sums up the session of every visible target
+func (m model) report() summary {
+	r := summary{End: m.now()}
+	for _, s := range m.targets[:m.visible()] {
+		if since := s.alerts.Since(); !since.IsZero() && (r.Start.IsZero() || since.Before(r.Start)) {
+			r.Start = since
+		}
+		t := targetSummary{
+			Name:        s.name,
+			Target:      s.ping.Target(),
+			Sent:        s.sent,
+			Received:    s.stat.Count,
+			Lost:        s.lost,
+			Reordered:   s.flying.counts[reordered],
+			Late:        s.flying.counts[late],
+			Duplicates:  s.flying.counts[duplicate],
+			Odd:         s.flying.odd(),
+			Min:         dur2ms(s.best),
+			Avg:         dur2ms(s.stat.Mean()),
+			Max:         dur2ms(s.worst),
+			StdDev:      dur2ms(s.stat.StdDev()),
+			Percentiles: map[string]float64{},
+			Incidents:   []incidentSummary{},
+		}
+		if t.Sent > 0 {
+			t.Loss = float64(t.Lost) / float64(t.Sent)
+		}
+		for _, q := range quantiles {
+			t.Percentiles[q.name] = dur2ms(s.quant.Quantile(q.q))
+		}
+		for _, in := range s.alerts.Incidents() {
+			i := incidentSummary{Start: in.Start, Duration: in.Duration(r.End).Seconds(), Probes: in.Probes, Lost: in.Lost, Worst: dur2ms(in.Worst)}
+			if !in.Ongoing() {
+				i.End = &in.End
+			}
+			t.Incidents = append(t.Incidents, i)
+		}
+		r.Targets = append(r.Targets, t)
+	}
+	if !r.Start.IsZero() {
+		r.Duration = r.End.Sub(r.Start).Seconds()
+	}
+	return r
+}
+
+// String is the summary for people, a paragraph per target (a bit like ping's)
+func (r summary) String() string {
+	if r.Start.IsZero() {
+		return "Bye-bye (nothing was probed)\n"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "monet: %s from %s to %s\n", lasted(r.End.Sub(r.Start)), r.Start.Format(time.DateTime), r.End.Format(time.TimeOnly))
+	for _, t := range r.Targets {
+		b.WriteString("\n")
+		if t.Name != `` {
+			fmt.Fprintf(&b, "%s (%s)\n", t.Name, t.Target)
+		} else {
+			fmt.Fprintf(&b, "%s\n", t.Target)
+		}
+		fmt.Fprintf(&b, "  %d sent, %d received, %d lost (%.1f%%)\n", t.Sent, t.Received, t.Lost, t.Loss*100)
+		if t.Odd != `` {
+			fmt.Fprintf(&b, "  replies: %s\n", t.Odd)
+		}
+		if t.Received > 0 {
+			fmt.Fprintf(&b, "  rtt min/avg/max/stddev = %.3f/%.3f/%.3f/%.3f ms\n", t.Min, t.Avg, t.Max, t.StdDev)
+			parts := make([]string, len(quantiles))
+			for i, q := range quantiles {
+				parts[i] = fmt.Sprintf(`%s %.1f`, q.name, t.Percentiles[q.name])
+			}
+			fmt.Fprintf(&b, "  %s ms\n", strings.Join(parts, `, `))
+		}
+		switch n := len(t.Incidents); n {
+		case 0:
+			b.WriteString("  no incidents\n")
+		default:
+			longest := t.Incidents[0]
+			for _, in := range t.Incidents[1:] {
+				if in.Duration > longest.Duration {
+					longest = in
+				}
+			}
+			noun := `incidents`
+			if n == 1 {
+				noun = `incident`
+			}
+			d := time.Duration(longest.Duration * float64(time.Second))
+			fmt.Fprintf(&b, "  %d %s, longest %s at %s\n", n, noun, lasted(d), longest.Start.Format(time.TimeOnly))
+		}
+	}
+	return b.String()
+}
+
+// write saves the summary as JSON to path (- being stdout)
+func (r summary) write(path string) error {
+	out := os.Stdout
+	if path != `-` {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent(``, `  `)
+	return enc.Encode(r)
+}
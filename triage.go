@@ -0,0 +1,59 @@
+package main
+
+import (
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/bign8/monet/internal/triage"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// hops are the indexes into `targets` of each part of the path being triaged (-1 when unknown)
+type hops struct {
+	lan, isp, target int
+}
+
+// triageTargets discovers the gateway and first ISP hop on the way to target: `monet triage [target]`
+func triageTargets(target string) ([]series, *hops) {
+	addr, err := net.ResolveIPAddr(`ip`, target)
+	chk(`Error resolving target`, err)
+	v6 := addr.IP.To4() == nil
+
+	gateway, err := triage.Gateway(v6)
+	chk(`Error finding default gateway`, err)
+
+	slog.Info(`looking for your ISP`, `target`, target, `gateway`, gateway)
+	isp, err := triage.ISPHop(addr.IP.String(), time.Second)
+	if err != nil {
+		slog.Warn(`unable to find ISP hop (continuing without it)`, `error`, err.Error())
+	}
+
+	h := &hops{lan: 0, isp: -1, target: 1}
+	all := []series{newSeries(`gateway `+gateway, gateway)}
+	if isp != `` {
+		h.isp, h.target = 1, 2
+		all = append(all, newSeries(`isp `+isp, isp))
+	}
+	all = append(all, newSeries(`target `+target, target))
+	return all, h
+}
+
+// verdict renders the triage line (whose fault is it?)
+func (m model) verdict() string {
+	health := func(i int) triage.Health {
+		if i < 0 {
+			return triage.Health{}
+		}
+		return m.targets[i].health(triageWindow)
+	}
+	line, blame := triage.Verdict(health(m.triage.lan), health(m.triage.isp), health(m.triage.target), m.triage.isp >= 0)
+	style := lipgloss.NewStyle().Bold(true)
+	if blame {
+		style = style.Foreground(RED)
+	}
+	return lipgloss.Place(m.w, 1, lipgloss.Center, lipgloss.Center, style.Render(line))
+}
+
+// how many of the most recent (answered or lost) probes are considered when triaging
+const triageWindow = 50